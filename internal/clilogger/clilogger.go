@@ -5,19 +5,22 @@ import (
 	"io"
 	"log/slog"
 	"strings"
+	"sync"
 )
 
 var _ slog.Handler = &Handler{}
 
 type Handler struct {
 	output io.Writer
+	// lock serializes writes to output, so concurrent log records don't interleave. It is shared by all clones.
+	lock   *sync.Mutex
 	level  slog.Level
 	attrs  []slog.Attr
 	groups groups
 }
 
 func NewHandler(w io.Writer, level slog.Level) *Handler {
-	return &Handler{output: w, level: level}
+	return &Handler{output: w, lock: &sync.Mutex{}, level: level}
 }
 
 func (h Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
@@ -35,6 +38,7 @@ func (h Handler) WithGroup(name string) slog.Handler {
 func (h Handler) clone() *Handler {
 	return &Handler{
 		output: h.output,
+		lock:   h.lock,
 		level:  h.level,
 		attrs:  h.attrs[:len(h.attrs):len(h.attrs)],
 		groups: h.groups[:len(h.groups):len(h.groups)],
@@ -53,6 +57,8 @@ func (h Handler) Handle(_ context.Context, record slog.Record) error {
 	line.WriteString(record.Message)
 	h.handleAttrs(&line, record)
 	line.WriteRune('\n')
+	h.lock.Lock()
+	defer h.lock.Unlock()
 	_, err := h.output.Write([]byte(line.String()))
 	return err
 }
@@ -3,7 +3,10 @@ package clilogger
 import (
 	"bytes"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"log/slog"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -58,6 +61,28 @@ func TestCLILogger(t *testing.T) {
 	}
 }
 
+func TestCLILogger_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(NewHandler(&buf, slog.LevelInfo))
+
+	const workers = 10
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.With("worker", i).Info("Hello world")
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, workers)
+	for _, line := range lines {
+		assert.Regexp(t, `^INFO Hello world \(worker=\d\)$`, line)
+	}
+}
+
 func Test_groups(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -6,7 +6,6 @@ import (
 	"github.com/clambin/seals/internal/inventory"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -14,6 +13,10 @@ import (
 )
 
 var (
+	addArgs = charmer.Arguments{
+		"scope": {Default: "", Help: "Sealing scope of the secret (strict, namespace-wide, cluster-wide). Default: inventory's scope"},
+	}
+
 	addCmd = &cobra.Command{
 		Use:   "add [flags] <secret> <sealed-secret> <namespace>",
 		Short: "Add a secret",
@@ -47,13 +50,19 @@ func addToInventory(inv *inventory.Inventory, source, destination, namespace str
 		namespace = namespaceFromSecret
 	}
 
+	// check scope is valid
+	scope := v.GetString("scope")
+	if err = validateScope(scope); err != nil {
+		return err
+	}
+
 	// check destination dir is writable
 	if err = isWritableDirectory(filepath.Dir(destination)); err != nil {
 		return fmt.Errorf("unable to check if destination directory exists: %w", err)
 	}
 
 	// make secret with relative paths
-	secret := inventory.Secret{Namespace: namespace}
+	secret := inventory.Secret{Namespace: namespace, Scope: scope}
 	if secret.Source, err = makeRelativePath(filepath.Join(v.GetString("ansible"), inv.SecretsDir), source); err != nil {
 		return fmt.Errorf("failed to make relative path: %w", err)
 	}
@@ -74,21 +83,28 @@ func addToInventory(inv *inventory.Inventory, source, destination, namespace str
 	return nil
 }
 
+// getNamespaceFromSecret checks that every document in the file is a Secret and returns their namespace.
+// Documents that specify a namespace must all specify the same one.
 func getNamespaceFromSecret(filename string) (string, error) {
-	f, err := os.Open(filename)
+	manifests, err := readManifests(filename)
 	if err != nil {
 		return "", err
 	}
-	var secret struct {
-		Kind     string `yaml:"kind"`
-		Metadata struct {
-			Namespace string `yaml:"namespace"`
-		} `yaml:"metadata"`
+	if len(manifests) == 0 {
+		return "", fmt.Errorf("no secrets found in %q", filename)
 	}
-	if err = yaml.NewDecoder(f).Decode(&secret); err == nil {
-		if secret.Kind != "Secret" {
-			err = fmt.Errorf("secret kind in %q must be 'Secret', got %q", filename, secret.Kind)
+	var namespace string
+	for _, m := range manifests {
+		if m.Kind != "Secret" {
+			return "", fmt.Errorf("secret kind in %q must be 'Secret', got %q", filename, m.Kind)
+		}
+		if m.Metadata.Namespace == "" {
+			continue
+		}
+		if namespace != "" && namespace != m.Metadata.Namespace {
+			return "", fmt.Errorf("secrets in %q have different namespaces: %q and %q", filename, namespace, m.Metadata.Namespace)
 		}
+		namespace = m.Metadata.Namespace
 	}
-	return secret.Metadata.Namespace, err
+	return namespace, nil
 }
@@ -18,6 +18,7 @@ func Test_addToInventory(t *testing.T) {
 		source       string
 		destination  string
 		namespace    string
+		scope        string
 		secretExists bool
 		wantErr      assert.ErrorAssertionFunc
 		wantSecret   inventory.Secret
@@ -32,6 +33,27 @@ func Test_addToInventory(t *testing.T) {
 			wantErr:      assert.NoError,
 			wantSecret:   inventory.Secret{Source: "secret.yaml", Destination: "sealed-secret.yaml", Namespace: "default"},
 		},
+		{
+			name:         "scope",
+			inv:          inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"},
+			source:       "secrets/secret.yaml",
+			destination:  "manifests/sealed-secret.yaml",
+			namespace:    "default",
+			scope:        "namespace-wide",
+			secretExists: true,
+			wantErr:      assert.NoError,
+			wantSecret:   inventory.Secret{Source: "secret.yaml", Destination: "sealed-secret.yaml", Namespace: "default", Scope: "namespace-wide"},
+		},
+		{
+			name:         "invalid scope",
+			inv:          inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"},
+			source:       "secrets/secret.yaml",
+			destination:  "manifests/sealed-secret.yaml",
+			namespace:    "default",
+			scope:        "invalid",
+			secretExists: true,
+			wantErr:      assert.Error,
+		},
 		{
 			name:         "different namespace",
 			inv:          inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"},
@@ -80,6 +102,7 @@ func Test_addToInventory(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			v := viper.New()
 			v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+			v.Set("scope", tt.scope)
 
 			source := filepath.Join(tmpdir, tt.source)
 			destination := filepath.Join(tmpdir, tt.destination)
@@ -115,3 +138,50 @@ func initFS(tmpdir string) error {
 	}
 	return nil
 }
+
+func Test_getNamespaceFromSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "single document",
+			content: "kind: Secret\nmetadata:\n  namespace: default\n",
+			want:    "default",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "multiple documents",
+			content: "kind: Secret\nmetadata:\n  name: foo\n---\nkind: Secret\nmetadata:\n  name: bar\n  namespace: default\n",
+			want:    "default",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "not a secret",
+			content: "kind: Secret\nmetadata:\n  namespace: default\n---\nkind: ConfigMap\n",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "different namespaces",
+			content: "kind: Secret\nmetadata:\n  namespace: default\n---\nkind: Secret\nmetadata:\n  namespace: other\n",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "empty",
+			content: "---\n",
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "secret.yaml")
+			require.NoError(t, os.WriteFile(filename, []byte(tt.content), 0644))
+			namespace, err := getNamespaceFromSecret(filename)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, namespace)
+		})
+	}
+}
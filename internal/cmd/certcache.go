@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"k8s.io/client-go/util/cert"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// certCache keeps the certificates fetched from sealed-secrets controllers on disk, so seal doesn't need to contact
+// the controller on every run.
+type certCache struct {
+	dir string
+	// maxAge is how long a cached certificate is reused. Zero means cached certificates are reused until they expire.
+	maxAge time.Duration
+}
+
+// certCacheKey identifies a controller: its kube context, namespace and name.
+type certCacheKey struct {
+	context   string
+	namespace string
+	name      string
+}
+
+// newCertCache returns a certCache that stores its certificates in the user's cache directory.
+func newCertCache(maxAge time.Duration) (*certCache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &certCache{dir: filepath.Join(dir, "seals", "certs"), maxAge: maxAge}, nil
+}
+
+func (c *certCache) path(key certCacheKey) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{key.context, key.namespace, key.name}, "\x00")))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".pem")
+}
+
+// get returns the cached certificate for the controller. It returns false if no certificate is cached, if the cached
+// certificate is older than the cache's max age, or if the certificate has expired.
+func (c *certCache) get(key certCacheKey) ([]byte, bool) {
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil || (c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge) {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	certs, err := cert.ParseCertsPEM(data)
+	if err != nil || len(certs) == 0 || time.Now().After(certs[0].NotAfter) {
+		return nil, false
+	}
+	return data, true
+}
+
+// put stores the certificate for the controller.
+func (c *certCache) put(key certCacheKey, data []byte) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path(key), 0600, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
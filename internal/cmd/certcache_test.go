@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCertCache(t *testing.T) {
+	c := certCache{dir: t.TempDir(), maxAge: time.Hour}
+	key := certCacheKey{context: "prod", namespace: "sealed-secrets", name: "sealed-secrets"}
+
+	// nothing cached
+	_, ok := c.get(key)
+	assert.False(t, ok)
+
+	// cached
+	require.NoError(t, c.put(key, []byte(testCert)))
+	cert, ok := c.get(key)
+	require.True(t, ok)
+	assert.Equal(t, testCert, string(cert))
+
+	// other contexts have their own certificate
+	_, ok = c.get(certCacheKey{context: "dev", namespace: "sealed-secrets", name: "sealed-secrets"})
+	assert.False(t, ok)
+
+	// cached certificate is too old
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(c.path(key), old, old))
+	_, ok = c.get(key)
+	assert.False(t, ok)
+
+	// no max age
+	c.maxAge = 0
+	_, ok = c.get(key)
+	assert.True(t, ok)
+
+	// expired certificate
+	require.NoError(t, c.put(key, expiredCert(t)))
+	_, ok = c.get(key)
+	assert.False(t, ok)
+}
+
+func expiredCert(t *testing.T) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     time.Now().Add(-time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+}
+
+func TestKubeSeal_CertCache(t *testing.T) {
+	cache := &certCache{dir: t.TempDir(), maxAge: time.Hour}
+	key := certCacheKey{context: "test", namespace: "sealed-secrets", name: "sealed-secrets"}
+
+	// a certificate that doesn't match the pinned fingerprint is not cached
+	ks := newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets", certCache: cache, certFingerprint: strings.Repeat("0", 64)})
+	ks.clientConfig = fakeController{cert: testCert}.start(t)
+	_, err := ks.getPublicKey()
+	require.Error(t, err)
+	_, ok := cache.get(key)
+	assert.False(t, ok)
+
+	// certificate is fetched from the controller and cached
+	ks = newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets", certCache: cache})
+	ks.clientConfig = fakeController{cert: testCert}.start(t)
+	_, err = ks.getPublicKey()
+	require.NoError(t, err)
+	cert, ok := cache.get(key)
+	require.True(t, ok)
+	assert.Equal(t, testCert, string(cert))
+
+	// cached certificate is used, even if the controller is unavailable
+	ks = newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets", certCache: cache})
+	ks.clientConfig = fakeController{}.start(t)
+	cert, err = ks.getCert()
+	require.NoError(t, err)
+	assert.Equal(t, testCert, string(cert))
+
+	// refreshCert bypasses the cache and caches the new certificate
+	newCert, _ := newTestKeyPair(t)
+	ks = newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets", certCache: cache, refreshCert: true})
+	ks.clientConfig = fakeController{cert: string(newCert)}.start(t)
+	_, err = ks.getPublicKey()
+	require.NoError(t, err)
+	cert, ok = cache.get(key)
+	require.True(t, ok)
+	assert.Equal(t, newCert, cert)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// confirmer asks the user to confirm a destructive action. It returns true if the action should proceed.
+type confirmer func(prompt string) bool
+
+// promptConfirmer returns a confirmer that writes the prompt to w and reads the answer from r.
+// Only "y" or "yes" (case-insensitive) is taken as confirmation.
+func promptConfirmer(r io.Reader, w io.Writer) confirmer {
+	scanner := bufio.NewScanner(r)
+	return func(prompt string) bool {
+		_, _ = fmt.Fprintf(w, "%s [y/N]: ", prompt)
+		if !scanner.Scan() {
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+		case "y", "yes":
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// alwaysConfirm is a confirmer that doesn't ask for confirmation.
+func alwaysConfirm(string) bool { return true }
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_promptConfirmer(t *testing.T) {
+	var out bytes.Buffer
+	confirm := promptConfirmer(strings.NewReader("y\nYES\nn\n\n"), &out)
+	assert.True(t, confirm("continue?"))
+	assert.True(t, confirm("continue?"))
+	assert.False(t, confirm("continue?"))
+	assert.False(t, confirm("continue?"))
+	assert.False(t, confirm("continue?"))
+	assert.Equal(t, strings.Repeat("continue? [y/N]: ", 5), out.String())
+}
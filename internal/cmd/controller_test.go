@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	ssv1alpha1 "github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/bitnami-labs/sealed-secrets/pkg/kubeseal"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeController is a local stand-in for the API server, proxying requests to a sealed-secrets controller.
+type fakeController struct {
+	// rotate handles /v1/rotate requests. If it returns nil, the request fails with http.StatusConflict.
+	rotate func(*ssv1alpha1.SealedSecret) *ssv1alpha1.SealedSecret
+	// verify handles /v1/verify requests. If it returns false, the request fails with http.StatusConflict.
+	verify func(*ssv1alpha1.SealedSecret) bool
+	// cert is returned by /v1/cert.pem
+	cert string
+	// name and namespace of the controller. Both default to "sealed-secrets".
+	name      string
+	namespace string
+}
+
+// start starts the fake controller. It returns a client configuration that connects to it.
+func (c fakeController) start(t *testing.T) kubeseal.ClientConfig {
+	t.Helper()
+	name := cmp.Or(c.name, "sealed-secrets")
+	namespace := cmp.Or(c.namespace, "sealed-secrets")
+	prefix := "/api/v1/namespaces/" + namespace + "/services/"
+	proxy := prefix + "http:" + name + ":http/proxy"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+name, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"kind":"Service","apiVersion":"v1","metadata":{"name":%q,"namespace":%q},"spec":{"ports":[{"name":"http","port":8080}]}}`, name, namespace)
+	})
+	mux.HandleFunc("GET "+proxy+"/v1/cert.pem", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(c.cert))
+	})
+	mux.HandleFunc("POST "+proxy+"/v1/verify", func(w http.ResponseWriter, r *http.Request) {
+		var ss ssv1alpha1.SealedSecret
+		if err := json.NewDecoder(r.Body).Decode(&ss); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !c.verify(&ss) {
+			http.Error(w, "unable to decrypt", http.StatusConflict)
+		}
+	})
+	mux.HandleFunc("POST "+proxy+"/v1/rotate", func(w http.ResponseWriter, r *http.Request) {
+		var ss ssv1alpha1.SealedSecret
+		if err := json.NewDecoder(r.Body).Decode(&ss); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rotated := c.rotate(&ss)
+		if rotated == nil {
+			http.Error(w, "unable to rotate", http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(rotated)
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return clientcmd.NewDefaultClientConfig(clientcmdapi.Config{
+		Clusters:       map[string]*clientcmdapi.Cluster{"test": {Server: s.URL}},
+		Contexts:       map[string]*clientcmdapi.Context{"test": {Cluster: "test"}},
+		CurrentContext: "test",
+	}, &clientcmd.ConfigOverrides{})
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"codeberg.org/clambin/go-common/charmer"
+	"errors"
+	"fmt"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+var (
+	discoverArgs = charmer.Arguments{
+		"pattern": {Default: "{{ .Dir }}/sealed-{{ .File }}", Help: "Template for the sealed secret's path, relative to the destination directory. Fields: .Dir, .File, .Name, .Namespace"},
+		"apply":   {Default: false, Help: "Add the discovered secrets to the inventory"},
+	}
+
+	discoverCmd = &cobra.Command{
+		Use:   "discover",
+		Short: "Discover secrets that are not in the inventory",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inventoryFile := viper.GetString("inventory")
+			inv, err := inventory.ReadFromFile(inventoryFile)
+			if err != nil {
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
+			}
+			secrets, err := discover(inv, viper.GetViper(), charmer.GetLogger(cmd))
+			if err != nil {
+				return fmt.Errorf("failed to discover secrets: %w", err)
+			}
+			printSecrets(os.Stdout, secrets)
+			if !viper.GetBool("apply") || len(secrets) == 0 {
+				return nil
+			}
+			if err = checkDestinations(inv, secrets, viper.GetViper()); err != nil {
+				return err
+			}
+			for _, secret := range secrets {
+				inv.Add(secret)
+			}
+			return inv.WriteToFile(inventoryFile)
+		},
+	}
+)
+
+// destinationPatternData holds the fields available to the discover pattern.
+type destinationPatternData struct {
+	// Dir is the directory of the secret, relative to the secrets directory
+	Dir string
+	// File is the filename of the secret
+	File string
+	// Name is the name of the secret
+	Name string
+	// Namespace is the namespace of the secret
+	Namespace string
+}
+
+// discover walks the secrets directory and returns an inventory entry for each secret that isn't in the inventory yet.
+// Secrets without a namespace are skipped, as they can't be sealed.
+func discover(inv inventory.Inventory, v *viper.Viper, l *slog.Logger) ([]inventory.Secret, error) {
+	pattern, err := template.New("pattern").Option("missingkey=error").Parse(v.GetString("pattern"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	known := make(map[string]struct{}, len(inv.Secrets))
+	for _, secret := range inv.Secrets {
+		known[filepath.Clean(secret.Source)] = struct{}{}
+	}
+
+	secretsDir := filepath.Join(v.GetString("ansible"), inv.SecretsDir)
+	var secrets []inventory.Secret
+	err = walkManifests(secretsDir, "Secret", l, func(source string, m manifest) error {
+		if _, ok := known[source]; ok {
+			return nil
+		}
+		if m.Metadata.Namespace == "" {
+			l.Warn("skipping secret without a namespace", "source", source, "name", m.Metadata.Name)
+			return nil
+		}
+		known[source] = struct{}{}
+		data := destinationPatternData{
+			Dir:       filepath.Dir(source),
+			File:      filepath.Base(source),
+			Name:      m.Metadata.Name,
+			Namespace: m.Metadata.Namespace,
+		}
+		var destination bytes.Buffer
+		if err := pattern.Execute(&destination, data); err != nil {
+			return fmt.Errorf("%s: invalid pattern: %w", source, err)
+		}
+		secrets = append(secrets, inventory.Secret{
+			Source:      source,
+			Destination: filepath.Clean(destination.String()),
+			Namespace:   data.Namespace,
+		})
+		return nil
+	})
+	return secrets, err
+}
+
+// checkDestinations checks that the destination directory of each secret exists and is writable.
+func checkDestinations(inv inventory.Inventory, secrets []inventory.Secret, v *viper.Viper) error {
+	var errs []error
+	for _, secret := range secrets {
+		_, destination := secretPaths(inv, secret, v)
+		if err := isWritableDirectory(filepath.Dir(destination)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid destination directory: %w", secret.Destination, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func printSecrets(w io.Writer, secrets []inventory.Secret) {
+	for _, secret := range secrets {
+		_, _ = fmt.Fprintf(w, "%s => %s (%s)\n", secret.Source, secret.Destination, secret.Namespace)
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_discover(t *testing.T) {
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpdir, "secrets", "app"), 0755))
+
+	files := map[string]string{
+		"known.yaml":        "kind: Secret\nmetadata:\n  name: known\n  namespace: default\n",
+		"app/new.yml":       "kind: Secret\nmetadata:\n  name: app-secret\n  namespace: app\n---\nkind: Secret\nmetadata:\n  name: other\n  namespace: app\n",
+		"configmap.yaml":    "kind: ConfigMap\nmetadata:\n  name: config\n",
+		"no-namespace.yaml": "kind: Secret\nmetadata:\n  name: no-namespace\n",
+		"invalid.yaml":      "kind: [Secret\n",
+		"README.md":         "kind: Secret\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "secrets", name), []byte(content), 0644))
+	}
+
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+	inv.Add(inventory.Secret{Source: "known.yaml", Destination: "sealed-known.yaml", Namespace: "default"})
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []inventory.Secret
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "default pattern",
+			pattern: "{{ .Dir }}/sealed-{{ .File }}",
+			want:    []inventory.Secret{{Source: "app/new.yml", Destination: "app/sealed-new.yml", Namespace: "app"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "custom pattern",
+			pattern: "{{ .Namespace }}/{{ .Name }}.yaml",
+			want:    []inventory.Secret{{Source: "app/new.yml", Destination: "app/app-secret.yaml", Namespace: "app"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid pattern",
+			pattern: "{{ .Foo }",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "unknown field",
+			pattern: "{{ .Foo }}",
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+			v.Set("pattern", tt.pattern)
+
+			secrets, err := discover(inv, v, slog.Default())
+			tt.wantErr(t, err)
+			if err == nil {
+				assert.Equal(t, tt.want, secrets)
+			}
+		})
+	}
+}
+
+func Test_checkDestinations(t *testing.T) {
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpdir, "manifests", "app"), 0755))
+
+	v := viper.New()
+	v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+
+	assert.NoError(t, checkDestinations(inv, []inventory.Secret{
+		{Source: "foo.yaml", Destination: "sealed-foo.yaml", Namespace: "default"},
+		{Source: "app/bar.yaml", Destination: "app/sealed-bar.yaml", Namespace: "app"},
+	}, v))
+
+	err := checkDestinations(inv, []inventory.Secret{{Source: "other/baz.yaml", Destination: "other/sealed-baz.yaml", Namespace: "default"}}, v)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "other/sealed-baz.yaml: invalid destination directory")
+}
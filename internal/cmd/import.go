@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"errors"
+	"fmt"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	importCmd = &cobra.Command{
+		Use:   "import [flags] <secrets-dir> <destination-dir>",
+		Short: "Import existing sealed secrets into the inventory",
+		Long: `Import scans the destination directory for sealed secrets and matches each one, by name and namespace,
+to a secret in the secrets directory. Matched secrets are added to the inventory. If the inventory doesn't exist yet,
+it is created. Both directories are relative to the ansible root directory.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return fmt.Errorf("expected 2 arguments, got %d", len(args))
+			}
+			inventoryFile := viper.GetString("inventory")
+			inv, err := inventory.ReadFromFile(inventoryFile)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				inv = inventory.Inventory{SecretsDir: args[0], DestinationDir: args[1]}
+			case err != nil:
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
+			case filepath.Clean(inv.SecretsDir) != filepath.Clean(args[0]) || filepath.Clean(inv.DestinationDir) != filepath.Clean(args[1]):
+				return fmt.Errorf("inventory uses different directories: %s, %s", inv.SecretsDir, inv.DestinationDir)
+			}
+
+			l := charmer.GetLogger(cmd)
+			matched, unmatched, err := importSecrets(inv, viper.GetViper(), l)
+			if err != nil {
+				return fmt.Errorf("failed to import sealed secrets: %w", err)
+			}
+			printSecrets(os.Stdout, matched)
+			for _, sealedSecret := range unmatched {
+				l.Warn("no secret found for sealed secret", "path", sealedSecret)
+			}
+			for _, secret := range matched {
+				inv.Add(secret)
+			}
+			return inv.WriteToFile(inventoryFile)
+		},
+	}
+)
+
+// importSecrets matches each SealedSecret in the destination directory to a Secret in the secrets directory. It returns
+// an inventory entry for each match and the paths of the sealed secrets for which no secret was found.
+// If a secret file matches more than one sealed secrets file, a sealed secrets file matches more than one secret file,
+// or a sealed secret matches a secret that is defined in more than one file, importSecrets returns an error.
+func importSecrets(inv inventory.Inventory, v *viper.Viper, l *slog.Logger) ([]inventory.Secret, []string, error) {
+	secretsDir := filepath.Join(v.GetString("ansible"), inv.SecretsDir)
+	secrets := make(map[string]string)
+	ambiguous := make(map[string][]string)
+	err := walkManifests(secretsDir, "Secret", l, func(source string, m manifest) error {
+		key := m.Metadata.Namespace + "/" + m.Metadata.Name
+		if other, ok := secrets[key]; ok && other != source {
+			if len(ambiguous[key]) == 0 {
+				ambiguous[key] = []string{other}
+			}
+			ambiguous[key] = append(ambiguous[key], source)
+			return nil
+		}
+		secrets[key] = source
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	destinationDir := filepath.Join(v.GetString("ansible"), inv.DestinationDir)
+	var matched []inventory.Secret
+	var unmatched []string
+	var conflicts []error
+	destinations := make(map[string]string)
+	sources := make(map[string]string)
+	err = walkManifests(destinationDir, "SealedSecret", l, func(destination string, m manifest) error {
+		key := m.Metadata.Namespace + "/" + m.Metadata.Name
+		source, ok := secrets[key]
+		if !ok {
+			// the secret may not specify a namespace
+			key = "/" + m.Metadata.Name
+			source, ok = secrets[key]
+		}
+		if !ok {
+			unmatched = append(unmatched, destination)
+			return nil
+		}
+		if files, ok := ambiguous[key]; ok {
+			conflicts = append(conflicts, fmt.Errorf("%s: secret %s is defined in %s", destination, m.Metadata.Name, strings.Join(files, ", ")))
+			return nil
+		}
+		other, sourceMatched := destinations[source]
+		if sourceMatched && other != destination {
+			conflicts = append(conflicts, fmt.Errorf("%s: matches both %s and %s", source, other, destination))
+			return nil
+		}
+		if other, ok := sources[destination]; ok && other != source {
+			conflicts = append(conflicts, fmt.Errorf("%s: matches both %s and %s", destination, other, source))
+			return nil
+		}
+		if sourceMatched {
+			// another document of the same secret/sealed secret pair
+			return nil
+		}
+		destinations[source] = destination
+		sources[destination] = source
+		matched = append(matched, inventory.Secret{Source: source, Destination: destination, Namespace: m.Metadata.Namespace})
+		return nil
+	})
+	if err == nil {
+		err = errors.Join(conflicts...)
+	}
+	return matched, unmatched, err
+}
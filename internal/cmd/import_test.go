@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_importSecrets(t *testing.T) {
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+
+	files := map[string]string{
+		"secrets/foo.yaml":          "kind: Secret\nmetadata:\n  name: foo\n  namespace: default\n",
+		"secrets/bar.yaml":          "kind: Secret\nmetadata:\n  name: bar\n",
+		"manifests/sealed-foo.yaml": "kind: SealedSecret\nmetadata:\n  name: foo\n  namespace: default\n",
+		"manifests/sealed-bar.yaml": "kind: SealedSecret\nmetadata:\n  name: bar\n  namespace: app\n",
+		"manifests/sealed-baz.yaml": "kind: SealedSecret\nmetadata:\n  name: baz\n  namespace: default\n",
+		"manifests/deployment.yaml": "kind: Deployment\nmetadata:\n  name: foo\n  namespace: default\n---\nkind: SealedSecret\nmetadata:\n  name: qux\n  namespace: default\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, name), []byte(content), 0644))
+	}
+
+	v := viper.New()
+	v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+
+	matched, unmatched, err := importSecrets(inv, v, slog.Default())
+	require.NoError(t, err)
+	assert.Equal(t, []inventory.Secret{
+		{Source: "bar.yaml", Destination: "sealed-bar.yaml", Namespace: "app"},
+		{Source: "foo.yaml", Destination: "sealed-foo.yaml", Namespace: "default"},
+	}, matched)
+	assert.Equal(t, []string{"deployment.yaml", "sealed-baz.yaml"}, unmatched)
+}
+
+func Test_importSecrets_multipleDocuments(t *testing.T) {
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+
+	files := map[string]string{
+		"secrets/app.yaml":          "kind: Secret\nmetadata:\n  name: foo\n  namespace: app\n---\nkind: Secret\nmetadata:\n  name: bar\n  namespace: app\n",
+		"manifests/sealed-app.yaml": "kind: SealedSecret\nmetadata:\n  name: foo\n  namespace: app\n---\nkind: SealedSecret\nmetadata:\n  name: bar\n  namespace: app\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, name), []byte(content), 0644))
+	}
+
+	v := viper.New()
+	v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+
+	matched, unmatched, err := importSecrets(inv, v, slog.Default())
+	require.NoError(t, err)
+	assert.Equal(t, []inventory.Secret{{Source: "app.yaml", Destination: "sealed-app.yaml", Namespace: "app"}}, matched)
+	assert.Empty(t, unmatched)
+}
+
+func Test_importSecrets_conflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name: "secret matches two sealed secrets",
+			files: map[string]string{
+				"secrets/foo.yaml":          "kind: Secret\nmetadata:\n  name: foo\n  namespace: default\n",
+				"manifests/sealed-foo.yaml": "kind: SealedSecret\nmetadata:\n  name: foo\n  namespace: default\n",
+				"manifests/other-foo.yaml":  "kind: SealedSecret\nmetadata:\n  name: foo\n  namespace: default\n",
+			},
+			wantErr: "foo.yaml: matches both other-foo.yaml and sealed-foo.yaml",
+		},
+		{
+			name: "sealed secrets file matches two secrets",
+			files: map[string]string{
+				"secrets/foo.yaml":      "kind: Secret\nmetadata:\n  name: foo\n  namespace: default\n",
+				"secrets/bar.yaml":      "kind: Secret\nmetadata:\n  name: bar\n  namespace: default\n",
+				"manifests/sealed.yaml": "kind: SealedSecret\nmetadata:\n  name: foo\n  namespace: default\n---\nkind: SealedSecret\nmetadata:\n  name: bar\n  namespace: default\n",
+			},
+			wantErr: "sealed.yaml: matches both foo.yaml and bar.yaml",
+		},
+		{
+			name: "secret defined in two files",
+			files: map[string]string{
+				"secrets/foo.yaml":          "kind: Secret\nmetadata:\n  name: foo\n  namespace: default\n",
+				"secrets/other-foo.yaml":    "kind: Secret\nmetadata:\n  name: foo\n  namespace: default\n",
+				"manifests/sealed-foo.yaml": "kind: SealedSecret\nmetadata:\n  name: foo\n  namespace: default\n",
+			},
+			wantErr: "sealed-foo.yaml: secret foo is defined in foo.yaml, other-foo.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpdir := t.TempDir()
+			require.NoError(t, initFS(tmpdir))
+			for name, content := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(tmpdir, name), []byte(content), 0644))
+			}
+
+			v := viper.New()
+			v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+			inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+
+			_, _, err := importSecrets(inv, v, slog.Default())
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/clambin/seals/internal/inventory"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"io"
+	"os"
 )
 
 var (
@@ -12,13 +14,25 @@ var (
 		Use:   "list",
 		Short: "Lists all secrets",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			inv, err := inventory.ReadFromFile(viper.GetString("inventory"))
-			if err == nil {
-				for _, secret := range inv.Secrets {
-					fmt.Printf("%s => %s (%s)\n", secret.Source, secret.Destination, secret.Namespace)
-				}
+			inventoryFile := viper.GetString("inventory")
+			inv, err := inventory.ReadFromFile(inventoryFile)
+			if err != nil {
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
 			}
-			return err
+			lock, err := inventory.ReadLockFromFile(inventory.LockPath(inventoryFile))
+			if err != nil {
+				return fmt.Errorf("unable to load lock file: %w", err)
+			}
+			return list(os.Stdout, inv, lock, viper.GetViper())
 		},
 	}
 )
+
+// list writes all secrets in the inventory. The table format doesn't show the secret's state.
+func list(w io.Writer, inv inventory.Inventory, lock *inventory.Lock, v *viper.Viper) error {
+	reports, err := newSecretReports(inv, lock, v)
+	if err != nil {
+		return err
+	}
+	return writeReports(w, v.GetString("output"), reports, false)
+}
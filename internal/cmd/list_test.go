@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_list(t *testing.T) {
+	inv := inventory.Inventory{SecretsDir: "secrets", DestinationDir: "manifests", Scope: "namespace-wide"}
+	inv.Add(inventory.Secret{Source: "foo.yaml", Destination: "sealed-foo.yaml", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "bar.yaml", Destination: "sealed-bar.yaml", Namespace: "default", Scope: "cluster-wide"})
+
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:   "table",
+			output: "table",
+			want: `SOURCE    DESTINATION      NAMESPACE  SCOPE
+foo.yaml  sealed-foo.yaml  default    namespace-wide
+bar.yaml  sealed-bar.yaml  default    cluster-wide
+`,
+			wantErr: assert.NoError,
+		},
+		{
+			name:   "json",
+			output: "json",
+			want: `[
+  {
+    "source": "foo.yaml",
+    "destination": "sealed-foo.yaml",
+    "source_path": "/ansible/secrets/foo.yaml",
+    "destination_path": "/ansible/manifests/sealed-foo.yaml",
+    "namespace": "default",
+    "scope": "namespace-wide",
+    "state": "source missing"
+  },
+  {
+    "source": "bar.yaml",
+    "destination": "sealed-bar.yaml",
+    "source_path": "/ansible/secrets/bar.yaml",
+    "destination_path": "/ansible/manifests/sealed-bar.yaml",
+    "namespace": "default",
+    "scope": "cluster-wide",
+    "state": "source missing"
+  }
+]
+`,
+			wantErr: assert.NoError,
+		},
+		{
+			name:   "yaml",
+			output: "yaml",
+			want: `- source: foo.yaml
+  destination: sealed-foo.yaml
+  source_path: /ansible/secrets/foo.yaml
+  destination_path: /ansible/manifests/sealed-foo.yaml
+  namespace: default
+  scope: namespace-wide
+  state: source missing
+- source: bar.yaml
+  destination: sealed-bar.yaml
+  source_path: /ansible/secrets/bar.yaml
+  destination_path: /ansible/manifests/sealed-bar.yaml
+  namespace: default
+  scope: cluster-wide
+  state: source missing
+`,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid",
+			output:  "xml",
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.Set("ansible", "/ansible")
+			v.Set("output", tt.output)
+
+			var out bytes.Buffer
+			tt.wantErr(t, list(&out, inv, &inventory.Lock{}, v))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func Test_list_clusters(t *testing.T) {
+	inv := inventory.Inventory{SecretsDir: "secrets", DestinationDir: "manifests", Clusters: []inventory.Cluster{{Name: "staging"}, {Name: "production"}}}
+	inv.Add(inventory.Secret{Source: "foo.yaml", Namespace: "default", Clusters: []inventory.Target{
+		{Cluster: "staging", Destination: "staging/sealed-foo.yaml"},
+		{Cluster: "production", Destination: "production/sealed-foo.yaml"},
+	}})
+	v := viper.New()
+	v.Set("ansible", "/ansible")
+
+	var out bytes.Buffer
+	assert.NoError(t, list(&out, inv, &inventory.Lock{}, v))
+	assert.Equal(t, `SOURCE    DESTINATION                              NAMESPACE  SCOPE
+foo.yaml  staging/sealed-foo.yaml (staging)        default    strict
+foo.yaml  production/sealed-foo.yaml (production)  default    strict
+`, out.String())
+}
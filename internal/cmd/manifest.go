@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// manifest holds the fields of a kubernetes manifest that we need to identify it.
+type manifest struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+}
+
+// readManifests reads all YAML documents in a file. Empty documents are skipped.
+func readManifests(path string) ([]manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var manifests []manifest
+	dec := yaml.NewDecoder(f)
+	for {
+		var m manifest
+		err = dec.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			return manifests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if m != (manifest{}) {
+			manifests = append(manifests, m)
+		}
+	}
+}
+
+// walkManifests calls fn for each manifest of the given kind in each YAML file below dir.
+// fn receives the path of the file relative to dir. If fn returns an error, the walk stops.
+func walkManifests(dir string, kind string, l *slog.Logger, fn func(path string, m manifest) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isYAML(path) {
+			return err
+		}
+		manifests, err := readManifests(path)
+		if err != nil {
+			l.Debug("skipping invalid YAML file", "path", path, "err", err)
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		for _, m := range manifests {
+			if m.Kind != kind {
+				continue
+			}
+			if err = fn(rel, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func isYAML(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
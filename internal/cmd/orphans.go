@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"fmt"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+var (
+	orphansArgs = charmer.Arguments{
+		"delete": {Default: false, Help: "Delete the orphaned sealed secrets"},
+		"yes":    {Default: false, Help: "Don't ask for confirmation before deleting files"},
+	}
+
+	orphansCmd = &cobra.Command{
+		Use:   "orphans",
+		Short: "List sealed secrets that are not in the inventory",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inv, err := inventory.ReadFromFile(viper.GetString("inventory"))
+			if err != nil {
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
+			}
+			l := charmer.GetLogger(cmd)
+			orphans, err := findOrphans(inv, viper.GetViper(), l)
+			if err != nil {
+				return fmt.Errorf("failed to find orphaned sealed secrets: %w", err)
+			}
+			if err = writeOrphans(os.Stdout, inv, orphans, viper.GetViper()); err != nil {
+				return err
+			}
+			if !viper.GetBool("delete") {
+				return nil
+			}
+			confirm := promptConfirmer(os.Stdin, os.Stdout)
+			if viper.GetBool("yes") {
+				confirm = alwaysConfirm
+			}
+			for _, orphan := range orphans {
+				if err = purge(orphan, confirm, l); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+)
+
+// stateOrphaned is the state of a sealed secret that isn't produced by any secret in the inventory.
+const stateOrphaned = "orphaned"
+
+// writeOrphans writes the path of each orphaned sealed secret to w. In json or yaml format, each orphan is written as a
+// report with state stateOrphaned.
+func writeOrphans(w io.Writer, inv inventory.Inventory, orphans []string, v *viper.Viper) error {
+	format := v.GetString("output")
+	if isTableOutput(format) {
+		for _, orphan := range orphans {
+			_, _ = fmt.Fprintln(w, orphan)
+		}
+		return nil
+	}
+	destinationDir := filepath.Join(v.GetString("ansible"), inv.DestinationDir)
+	reports := make([]secretReport, 0, len(orphans))
+	for _, orphan := range orphans {
+		report := secretReport{State: stateOrphaned}
+		var err error
+		if report.Destination, err = filepath.Rel(destinationDir, orphan); err != nil {
+			return err
+		}
+		if report.DestinationPath, err = filepath.Abs(orphan); err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	return writeReports(w, format, reports, true)
+}
+
+// findOrphans walks the destination directory and returns the path of each SealedSecret that isn't produced by any
+// secret in the inventory.
+func findOrphans(inv inventory.Inventory, v *viper.Viper, l *slog.Logger) ([]string, error) {
+	known := make(map[string]struct{}, len(inv.Secrets))
+	for _, job := range sealJobs(inv.Secrets) {
+		known[filepath.Clean(job.target.Destination)] = struct{}{}
+	}
+
+	destinationDir := filepath.Join(v.GetString("ansible"), inv.DestinationDir)
+	var orphans []string
+	err := walkManifests(destinationDir, "SealedSecret", l, func(destination string, _ manifest) error {
+		if _, ok := known[destination]; !ok {
+			orphans = append(orphans, filepath.Join(destinationDir, destination))
+			known[destination] = struct{}{}
+		}
+		return nil
+	})
+	return orphans, err
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_findOrphans(t *testing.T) {
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpdir, "manifests", "app"), 0755))
+
+	files := map[string]string{
+		"sealed-known.yaml":     "kind: SealedSecret\nmetadata:\n  name: known\n",
+		"app/sealed-orphan.yml": "---\nkind: SealedSecret\nmetadata:\n  name: orphan\n---\nkind: SealedSecret\nmetadata:\n  name: other\n",
+		"deployment.yaml":       "kind: Deployment\nmetadata:\n  name: app\n",
+		"invalid.yaml":          "kind: [SealedSecret\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "manifests", name), []byte(content), 0644))
+	}
+
+	v := viper.New()
+	v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+	inv.Add(inventory.Secret{Source: "known.yaml", Destination: "sealed-known.yaml", Namespace: "default"})
+
+	orphans, err := findOrphans(inv, v, slog.Default())
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(tmpdir, "manifests", "app", "sealed-orphan.yml")}, orphans)
+
+	var out bytes.Buffer
+	require.NoError(t, writeOrphans(&out, inv, orphans, v))
+	assert.Equal(t, filepath.Join(tmpdir, "manifests", "app", "sealed-orphan.yml")+"\n", out.String())
+
+	out.Reset()
+	v.Set("output", "yaml")
+	require.NoError(t, writeOrphans(&out, inv, orphans, v))
+	assert.Equal(t, `- source: ""
+  destination: app/sealed-orphan.yml
+  source_path: ""
+  destination_path: `+filepath.Join(tmpdir, "manifests", "app", "sealed-orphan.yml")+`
+  namespace: ""
+  scope: ""
+  state: orphaned
+`, out.String())
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"encoding/json"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+var outputArgs = charmer.Arguments{
+	"output": {Default: "table", Help: "Output format (table, json, yaml)"},
+}
+
+// secretReport is the machine-readable representation of a secret in the inventory.
+// Its fields are part of the json/yaml output format and should not be changed.
+type secretReport struct {
+	Source          string `json:"source" yaml:"source"`
+	Destination     string `json:"destination" yaml:"destination"`
+	Cluster         string `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	SourcePath      string `json:"source_path" yaml:"source_path"`
+	DestinationPath string `json:"destination_path" yaml:"destination_path"`
+	Namespace       string `json:"namespace" yaml:"namespace"`
+	Scope           string `json:"scope" yaml:"scope"`
+	State           string `json:"state" yaml:"state"`
+}
+
+// newSecretReports creates a secretReport, including its sealing state, for each cluster of each secret in the inventory.
+func newSecretReports(inv inventory.Inventory, lock *inventory.Lock, v *viper.Viper) ([]secretReport, error) {
+	reports := make([]secretReport, 0, len(inv.Secrets))
+	for _, job := range sealJobs(inv.Secrets) {
+		report, err := newSecretReport(inv, job, v)
+		if err != nil {
+			return nil, err
+		}
+		if report.State == "" {
+			report.State = secretState(inv, lock, job, v)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// newSecretReport creates a secretReport for the job's secret, with absolute paths and its effective sealing scope.
+// If the scope is invalid, the report holds the scope as configured, with state stateInvalidScope.
+func newSecretReport(inv inventory.Inventory, job sealJob, v *viper.Viper) (secretReport, error) {
+	secret := job.targetSecret()
+	source, destination := secretPaths(inv, secret, v)
+	report := secretReport{
+		Source:      secret.Source,
+		Destination: secret.Destination,
+		Cluster:     job.target.Cluster,
+		Namespace:   secret.Namespace,
+	}
+	var err error
+	if report.SourcePath, err = filepath.Abs(source); err != nil {
+		return report, err
+	}
+	if report.DestinationPath, err = filepath.Abs(destination); err != nil {
+		return report, err
+	}
+	var scope v1alpha1.SealingScope
+	if err = scope.Set(inv.SecretScope(secret)); err != nil {
+		report.Scope = inv.SecretScope(secret)
+		report.State = stateInvalidScope
+		return report, nil
+	}
+	report.Scope = scope.String()
+	return report, nil
+}
+
+// writeReports writes the reports to w in the requested format: table, json or yaml.
+// withState determines if the table format includes the secret's state.
+func writeReports(w io.Writer, format string, reports []secretReport, withState bool) error {
+	switch {
+	case isTableOutput(format):
+		return writeTable(w, reports, withState)
+	case strings.ToLower(format) == "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+	case strings.ToLower(format) == "yaml":
+		enc := yaml.NewEncoder(w)
+		defer func() { _ = enc.Close() }()
+		enc.SetIndent(2)
+		return enc.Encode(reports)
+	default:
+		return fmt.Errorf("unsupported output format: %q", format)
+	}
+}
+
+// isTableOutput returns true if format is the (default) table format, i.e. output meant for humans.
+func isTableOutput(format string) bool {
+	format = strings.ToLower(format)
+	return format == "table" || format == ""
+}
+
+func writeTable(w io.Writer, reports []secretReport, withState bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "SOURCE\tDESTINATION\tNAMESPACE\tSCOPE"
+	if withState {
+		header += "\tSTATE"
+	}
+	_, _ = fmt.Fprintln(tw, header)
+	for _, report := range reports {
+		destination := report.Destination
+		if report.Cluster != "" {
+			destination += " (" + report.Cluster + ")"
+		}
+		line := strings.Join([]string{report.Source, destination, report.Namespace, report.Scope}, "\t")
+		if withState {
+			line += "\t" + report.State
+		}
+		_, _ = fmt.Fprintln(tw, line)
+	}
+	return tw.Flush()
+}
@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
 	"golang.org/x/sys/unix"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -21,17 +26,67 @@ func makeRelativePath(base string, source string) (string, error) {
 	return filepath.Rel(base, source)
 }
 
-func shouldUpdate(source, destination string) (bool, error) {
+// secretPaths returns the location of the secret's source and destination files.
+func secretPaths(inv inventory.Inventory, secret inventory.Secret, v *viper.Viper) (string, string) {
+	ansibleDir := v.GetString("ansible")
+	return filepath.Join(ansibleDir, inv.SecretsDir, secret.Source), filepath.Join(ansibleDir, inv.DestinationDir, secret.Destination)
+}
+
+// shouldUpdate determines if destination needs to be (re)created from source. See updateReason.
+func shouldUpdate(source, destination, digest string) (bool, error) {
+	reason, err := updateReason(source, destination, digest)
+	return reason != upToDate, err
+}
+
+// reason why a secret needs to be sealed.
+const (
+	upToDate           = ""
+	destinationMissing = "destination missing"
+	sourceChanged      = "source changed"
+	sourceNewer        = "source newer"
+	forced             = "forced"
+)
+
+// updateReason returns why destination needs to be (re)created from source, or upToDate if it doesn't. If digest is set,
+// source is compared to the digest. Otherwise, it falls back to comparing the modification times of source and destination.
+func updateReason(source, destination, digest string) (string, error) {
 	sourceFInfo, err := os.Stat(source)
 	if err != nil {
-		return false, fmt.Errorf("%s does not exist", source)
+		return upToDate, fmt.Errorf("%s does not exist", source)
 	}
 
 	destinationFInfo, err := os.Stat(destination)
-	if err == nil {
-		return sourceFInfo.ModTime().After(destinationFInfo.ModTime()), nil
+	if err != nil {
+		return destinationMissing, nil
 	}
-	return true, nil
+	if digest != "" {
+		current, err := digestFile(source)
+		if err != nil {
+			return upToDate, err
+		}
+		if current != digest {
+			return sourceChanged, nil
+		}
+		return upToDate, nil
+	}
+	if sourceFInfo.ModTime().After(destinationFInfo.ModTime()) {
+		return sourceNewer, nil
+	}
+	return upToDate, nil
+}
+
+// digestFile returns the hex-encoded SHA-256 digest of the file's content.
+func digestFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 func isWritableDirectory(path string) error {
@@ -47,3 +102,32 @@ func isWritableDirectory(path string) error {
 	}
 	return nil
 }
+
+// writeFileAtomic writes the output of write to a temporary file in the same directory as path and renames it to path
+// on success. On failure, the temporary file is removed and any existing file at path is left untouched.
+func writeFileAtomic(path string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return fmt.Errorf("chmod: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+	return nil
+}
@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -67,9 +69,13 @@ func Test_shouldUpdate(t *testing.T) {
 	require.NoError(t, os.Chtimes(filepath.Join(tmpdir, "file1"), time.Now(), time.Now().Add(-time.Hour)))
 	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "file2"), []byte("content"), 0644))
 
+	digest, err := digestFile(filepath.Join(tmpdir, "file1"))
+	require.NoError(t, err)
+
 	type args struct {
 		source      string
 		destination string
+		digest      string
 	}
 	tests := []struct {
 		name    string
@@ -95,6 +101,18 @@ func Test_shouldUpdate(t *testing.T) {
 			want:    true,
 			wantErr: assert.NoError,
 		},
+		{
+			name:    "digest matches",
+			args:    args{source: "file2", destination: "file1", digest: digest},
+			want:    false,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "digest differs",
+			args:    args{source: "file1", destination: "file2", digest: "1234"},
+			want:    true,
+			wantErr: assert.NoError,
+		},
 		{
 			name:    "missing source file",
 			args:    args{source: "missing", destination: "file2"},
@@ -104,13 +122,40 @@ func Test_shouldUpdate(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := shouldUpdate(filepath.Join(tmpdir, tt.args.source), filepath.Join(tmpdir, tt.args.destination))
+			got, err := shouldUpdate(filepath.Join(tmpdir, tt.args.source), filepath.Join(tmpdir, tt.args.destination), tt.args.digest)
 			assert.Equal(t, tt.want, got)
 			tt.wantErr(t, err)
 		})
 	}
 }
 
+func Test_updateReason(t *testing.T) {
+	tmpdir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "file1"), []byte("content"), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(tmpdir, "file1"), time.Now(), time.Now().Add(-time.Hour)))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "file2"), []byte("content"), 0644))
+
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+		digest      string
+		want        string
+	}{
+		{name: "up to date", source: "file1", destination: "file2", want: upToDate},
+		{name: "source newer", source: "file2", destination: "file1", want: sourceNewer},
+		{name: "destination missing", source: "file1", destination: "missing", want: destinationMissing},
+		{name: "source changed", source: "file1", destination: "file2", digest: "1234", want: sourceChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := updateReason(filepath.Join(tmpdir, tt.source), filepath.Join(tmpdir, tt.destination), tt.digest)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func Test_isWritableDirectory(t *testing.T) {
 	tmpdir := t.TempDir()
 	require.NoError(t, initFS(tmpdir))
@@ -123,3 +168,33 @@ func Test_isWritableDirectory(t *testing.T) {
 	assert.Error(t, isWritableDirectory(filepath.Join(tmpdir, "not-a-directory")))
 	assert.Error(t, isWritableDirectory(filepath.Join(tmpdir, "read-only")))
 }
+
+func Test_writeFileAtomic(t *testing.T) {
+	tmpdir := t.TempDir()
+	target := filepath.Join(tmpdir, "target")
+
+	require.NoError(t, writeFileAtomic(target, 0600, func(w io.Writer) error {
+		_, err := w.Write([]byte("first"))
+		return err
+	}))
+	content, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(content))
+	fInfo, err := os.Stat(target)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fInfo.Mode().Perm())
+
+	assert.Error(t, writeFileAtomic(target, 0600, func(w io.Writer) error {
+		_, _ = w.Write([]byte("second"))
+		return errors.New("failed")
+	}))
+	content, err = os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(content))
+
+	entries, err := os.ReadDir(tmpdir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.Error(t, writeFileAtomic(filepath.Join(tmpdir, "missing", "target"), 0600, func(w io.Writer) error { return nil }))
+}
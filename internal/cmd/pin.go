@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/util/cert"
+	"slices"
+	"strings"
+)
+
+var (
+	pinCmd = &cobra.Command{
+		Use:   "pin",
+		Short: "Pin the controller's certificate in the inventory",
+		Long: `Pin records the SHA-256 fingerprint of the sealed-secrets controller's certificate in the inventory.
+Once pinned, seal refuses to seal secrets with a certificate that doesn't match the fingerprint.
+With --cluster, pin records the fingerprint of that cluster's controller.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inv, err := inventory.ReadFromFile(viper.GetString("inventory"))
+			if err != nil {
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
+			}
+			clusterName := viper.GetString("cluster")
+			cfg := controllerSealerConfig(inv, viper.GetViper())
+			cfg.certFile = certFile(inv, viper.GetViper())
+			if clusterName != "" {
+				cluster, ok := inv.Cluster(clusterName)
+				if !ok {
+					return fmt.Errorf("unknown cluster %q", clusterName)
+				}
+				cfg = clusterSealerConfig(inv, cluster, nil, viper.GetViper())
+				if cert := viper.GetString("cert"); cert != "" {
+					cfg.certFile = cert
+				}
+			}
+			fingerprint, err := pin(newKubeSealer(cfg), &inv, clusterName)
+			if err != nil {
+				return err
+			}
+			charmer.GetLogger(cmd).Info("certificate pinned", "fingerprint", fingerprint)
+			return inv.WriteToFile(viper.GetString("inventory"))
+		},
+	}
+
+	pinArgs = charmer.Arguments{
+		"cluster": {Default: "", Help: "Pin the certificate of this cluster's controller"},
+	}
+)
+
+// certGetter interface so we can stub during unit testing
+type certGetter interface {
+	getCert() ([]byte, error)
+}
+
+// pin records the fingerprint of the controller's certificate in the inventory. If cluster is set, the fingerprint is
+// recorded for that cluster. It returns the fingerprint.
+func pin(g certGetter, inv *inventory.Inventory, cluster string) (string, error) {
+	idx := slices.IndexFunc(inv.Clusters, func(c inventory.Cluster) bool { return c.Name == cluster })
+	if cluster != "" && idx == -1 {
+		return "", fmt.Errorf("unknown cluster %q", cluster)
+	}
+	c, err := g.getCert()
+	if err != nil {
+		return "", fmt.Errorf("unable to get controller certificate: %w", err)
+	}
+	fingerprint, err := certFingerprint(c)
+	if err != nil {
+		return "", err
+	}
+	if cluster != "" {
+		inv.Clusters[idx].CertFingerprint = fingerprint
+	} else {
+		inv.CertFingerprint = fingerprint
+	}
+	return fingerprint, nil
+}
+
+// certFingerprint returns the SHA-256 fingerprint of the first certificate in the PEM-encoded data.
+func certFingerprint(data []byte) (string, error) {
+	certs, err := cert.ParseCertsPEM(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid certificate: %w", err)
+	}
+	if len(certs) == 0 {
+		return "", errors.New("invalid certificate: no certificates found")
+	}
+	sum := sha256.Sum256(certs[0].Raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sameFingerprint compares two fingerprints, ignoring case and colons, so fingerprints reported by openssl also match.
+func sameFingerprint(a, b string) bool {
+	normalize := func(s string) string { return strings.ToLower(strings.ReplaceAll(s, ":", "")) }
+	return normalize(a) == normalize(b)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_pin(t *testing.T) {
+	var inv inventory.Inventory
+	ks := newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets"})
+	ks.clientConfig = fakeController{cert: testCert}.start(t)
+	fingerprint, err := pin(ks, &inv, "")
+	require.NoError(t, err)
+	assert.Len(t, inv.CertFingerprint, 64)
+	assert.Equal(t, inv.CertFingerprint, fingerprint)
+
+	// sealing with the pinned certificate succeeds
+	ks = newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets", certFingerprint: inv.CertFingerprint})
+	ks.clientConfig = fakeController{cert: testCert}.start(t)
+	_, err = ks.getPublicKey()
+	assert.NoError(t, err)
+
+	// controller unavailable
+	_, err = pin(fakeCertGetter{err: errors.New("fail")}, &inv, "")
+	assert.Error(t, err)
+	// invalid certificate
+	_, err = pin(fakeCertGetter{cert: []byte("not a certificate")}, &inv, "")
+	assert.Error(t, err)
+}
+
+func Test_pin_cluster(t *testing.T) {
+	v := viper.New()
+	inv := inventory.Inventory{Clusters: []inventory.Cluster{{Name: "staging"}, {Name: "production", ControllerName: "sealer"}}}
+	production, _ := inv.Cluster("production")
+	cfg := clusterSealerConfig(inv, production, nil, v)
+	assert.Equal(t, "sealer", cfg.controllerName)
+
+	// the fingerprint is recorded for the cluster only
+	ks := newKubeSealer(cfg)
+	ks.clientConfig = fakeController{cert: testCert, name: "sealer"}.start(t)
+	fingerprint, err := pin(ks, &inv, "production")
+	require.NoError(t, err)
+	assert.Empty(t, inv.CertFingerprint)
+	assert.Empty(t, inv.Clusters[0].CertFingerprint)
+	assert.Equal(t, fingerprint, inv.Clusters[1].CertFingerprint)
+
+	// sealing for the cluster uses the pinned certificate
+	production, _ = inv.Cluster("production")
+	ks = newKubeSealer(clusterSealerConfig(inv, production, nil, v))
+	ks.clientConfig = fakeController{cert: testCert, name: "sealer"}.start(t)
+	_, err = ks.getPublicKey()
+	assert.NoError(t, err)
+
+	// unknown cluster
+	_, err = pin(fakeCertGetter{cert: []byte(testCert)}, &inv, "unknown")
+	assert.EqualError(t, err, `unknown cluster "unknown"`)
+}
+
+type fakeCertGetter struct {
+	cert []byte
+	err  error
+}
+
+func (f fakeCertGetter) getCert() ([]byte, error) {
+	return f.cert, f.err
+}
+
+func Test_sameFingerprint(t *testing.T) {
+	assert.True(t, sameFingerprint("abcd", "abcd"))
+	assert.True(t, sameFingerprint("abcd", "AB:CD"))
+	assert.False(t, sameFingerprint("abcd", "abce"))
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"codeberg.org/clambin/go-common/charmer"
+	"errors"
+	"fmt"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"log/slog"
+	"os"
+)
+
+var (
+	reencryptCmd = &cobra.Command{
+		Use:   "reencrypt",
+		Short: "Re-encrypt all sealed secrets with the controller's latest key",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inv, err := inventory.ReadFromFile(viper.GetString("inventory"))
+			if err != nil {
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
+			}
+			kubeSealers, err := newKubeSealers(inv, nil, viper.GetViper())
+			if err != nil {
+				return err
+			}
+			s := make(reencrypters, len(kubeSealers))
+			for name, ks := range kubeSealers {
+				s[name] = ks
+			}
+			return reencrypt(s, inv, viper.GetViper(), charmer.GetLogger(cmd))
+		},
+	}
+)
+
+// reencrypter interface so we can stub during unit testing
+type reencrypter interface {
+	reencrypt(w io.Writer, r io.Reader) error
+}
+
+// reencrypters holds the reencrypter for each cluster in the inventory. The reencrypter for the default cluster has
+// no name.
+type reencrypters map[string]reencrypter
+
+// reencrypt re-encrypts the sealed secrets of every secret in the inventory, with the controller of each cluster.
+// This doesn't require access to the secrets.
+func reencrypt(r reencrypters, inv inventory.Inventory, v *viper.Viper, l *slog.Logger) error {
+	var errs []error
+	for _, job := range sealJobs(inv.Secrets) {
+		secret := job.targetSecret()
+		_, sealedSecretFile := secretPaths(inv, secret, v)
+		err := fmt.Errorf("unknown cluster %q", job.target.Cluster)
+		if clusterReencrypter, ok := r[job.target.Cluster]; ok {
+			err = reencryptFile(clusterReencrypter, sealedSecretFile)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to re-encrypt %q: %w", secret.Destination, err))
+			continue
+		}
+		job.logger(l).Info("sealed secret re-encrypted", "secret", secret.Destination)
+	}
+	return errors.Join(errs...)
+}
+
+func reencryptFile(r reencrypter, path string) error {
+	sealedSecret, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, 0644, func(w io.Writer) error {
+		return r.reencrypt(w, bytes.NewReader(sealedSecret))
+	})
+}
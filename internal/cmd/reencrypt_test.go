@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	ssv1alpha1 "github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_reencrypt(t *testing.T) {
+	tmpdir := t.TempDir()
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "sealed-foo"), []byte("foo"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "sealed-bar"), []byte("fail"), 0644))
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: "."}
+	inv.Add(inventory.Secret{Source: "foo", Destination: "sealed-foo", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "bar", Destination: "sealed-bar", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "missing", Destination: "sealed-missing", Namespace: "default"})
+
+	err := reencrypt(reencrypters{"": fakeReencrypter{}}, inv, v, slog.Default())
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "sealed-foo")
+	assert.Contains(t, err.Error(), `failed to re-encrypt "sealed-bar"`)
+	assert.Contains(t, err.Error(), `failed to re-encrypt "sealed-missing"`)
+
+	content, err := os.ReadFile(filepath.Join(tmpdir, "sealed-foo"))
+	require.NoError(t, err)
+	assert.Equal(t, "FOO", string(content))
+	content, err = os.ReadFile(filepath.Join(tmpdir, "sealed-bar"))
+	require.NoError(t, err)
+	assert.Equal(t, "fail", string(content))
+}
+
+func Test_reencrypt_clusters(t *testing.T) {
+	tmpdir := t.TempDir()
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+
+	for _, cluster := range []string{"staging", "production"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, cluster+"-sealed"), []byte(cluster), 0644))
+	}
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: ".", Clusters: []inventory.Cluster{{Name: "staging"}, {Name: "production"}}}
+	inv.Add(inventory.Secret{Source: "secret", Namespace: "default", Clusters: []inventory.Target{
+		{Cluster: "staging", Destination: "staging-sealed"},
+		{Cluster: "production", Destination: "production-sealed"},
+	}})
+
+	// production has no reencrypter
+	err := reencrypt(reencrypters{"staging": fakeReencrypter{}}, inv, v, slog.Default())
+	assert.EqualError(t, err, `failed to re-encrypt "production-sealed": unknown cluster "production"`)
+
+	content, err := os.ReadFile(filepath.Join(tmpdir, "staging-sealed"))
+	require.NoError(t, err)
+	assert.Equal(t, "STAGING", string(content))
+	content, err = os.ReadFile(filepath.Join(tmpdir, "production-sealed"))
+	require.NoError(t, err)
+	assert.Equal(t, "production", string(content))
+}
+
+var _ reencrypter = fakeReencrypter{}
+
+type fakeReencrypter struct{}
+
+func (f fakeReencrypter) reencrypt(w io.Writer, r io.Reader) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if string(body) == "fail" {
+		return errors.New("unable to rotate secret")
+	}
+	_, err = w.Write(bytes.ToUpper(body))
+	return err
+}
+
+func TestKubeSeal_Reencrypt(t *testing.T) {
+	ks := newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets"})
+	ks.clientConfig = fakeController{
+		rotate: func(ss *ssv1alpha1.SealedSecret) *ssv1alpha1.SealedSecret {
+			ss.Spec.EncryptedData["PASS"] = "rotated"
+			return ss
+		},
+	}.start(t)
+
+	const sealedSecret = `apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: my-secret
+  namespace: my-namespace
+spec:
+  encryptedData:
+    PASS: original
+`
+	var output bytes.Buffer
+	require.NoError(t, ks.reencrypt(&output, strings.NewReader(sealedSecret)))
+
+	var rotated ssv1alpha1.SealedSecret
+	require.NoError(t, runtime.DecodeInto(scheme.Codecs.UniversalDecoder(), output.Bytes(), &rotated))
+	assert.Equal(t, "my-secret", rotated.GetName())
+	assert.Equal(t, "rotated", rotated.Spec.EncryptedData["PASS"])
+}
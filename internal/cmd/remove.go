@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"errors"
+	"fmt"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+var (
+	removeArgs = charmer.Arguments{
+		"purge":        {Default: false, Help: "Also delete the sealed secret"},
+		"purge-source": {Default: false, Help: "Also delete the secret"},
+		"yes":          {Default: false, Help: "Don't ask for confirmation before deleting files"},
+	}
+
+	removeCmd = &cobra.Command{
+		Use:   "remove [flags] <secret>",
+		Short: "Remove a secret",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected 1 argument, got %d", len(args))
+			}
+			inventoryFile := viper.GetString("inventory")
+			inv, err := inventory.ReadFromFile(inventoryFile)
+			if err != nil {
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
+			}
+			lockFile := inventory.LockPath(inventoryFile)
+			lock, err := inventory.ReadLockFromFile(lockFile)
+			if err != nil {
+				return fmt.Errorf("unable to load lock file: %w", err)
+			}
+			confirm := promptConfirmer(os.Stdin, os.Stdout)
+			if viper.GetBool("yes") {
+				confirm = alwaysConfirm
+			}
+			if err = removeFromInventory(&inv, lock, args[0], viper.GetViper(), confirm, charmer.GetLogger(cmd)); err != nil {
+				return fmt.Errorf("failed to remove secret: %w", err)
+			}
+			if err = inv.WriteToFile(inventoryFile); err != nil {
+				return err
+			}
+			if err = lock.WriteToFile(lockFile); err != nil {
+				return fmt.Errorf("unable to write lock file: %w", err)
+			}
+			return nil
+		},
+	}
+)
+
+// removeFromInventory removes the secret from the inventory, along with the digests recorded for it in the lock.
+func removeFromInventory(inv *inventory.Inventory, lock *inventory.Lock, source string, v *viper.Viper, confirm confirmer, l *slog.Logger) error {
+	secrets, err := selectSecrets(*inv, []string{source}, v)
+	if err != nil {
+		return err
+	}
+	secret := secrets[0]
+
+	secretFile, _ := secretPaths(*inv, secret, v)
+	if v.GetBool("purge") {
+		sealedSecretFiles, err := sealedSecretPaths(*inv, secret, v)
+		if err != nil {
+			return err
+		}
+		for _, sealedSecretFile := range sealedSecretFiles {
+			if err = purge(sealedSecretFile, confirm, l); err != nil {
+				return err
+			}
+		}
+	}
+	if v.GetBool("purge-source") {
+		if err = purge(secretFile, confirm, l); err != nil {
+			return err
+		}
+	}
+
+	inv.Delete(secret.Source)
+	for _, job := range sealJobs([]inventory.Secret{secret}) {
+		lock.DeleteDigest(job.lockKey())
+	}
+	return nil
+}
+
+// sealedSecretPaths returns the location of the secret's sealed secret for each cluster it targets. It refuses to
+// return the destination directory itself, so a secret without a destination can't purge the whole directory.
+func sealedSecretPaths(inv inventory.Inventory, secret inventory.Secret, v *viper.Viper) ([]string, error) {
+	destinationDir := filepath.Clean(filepath.Join(v.GetString("ansible"), inv.DestinationDir))
+	var paths []string
+	for _, job := range sealJobs([]inventory.Secret{secret}) {
+		_, sealedSecretFile := secretPaths(inv, job.targetSecret(), v)
+		if filepath.Clean(sealedSecretFile) == destinationDir {
+			return nil, fmt.Errorf("%s has no destination", job.name())
+		}
+		paths = append(paths, sealedSecretFile)
+	}
+	return paths, nil
+}
+
+func purge(path string, confirm confirmer, l *slog.Logger) error {
+	if !confirm(fmt.Sprintf("delete %s?", path)) {
+		l.Info("not deleting file", "path", path)
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("unable to delete %q: %w", path, err)
+	}
+	l.Info("deleted file", "path", path)
+	return nil
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_removeFromInventory(t *testing.T) {
+	tests := []struct {
+		name             string
+		source           string
+		purge            bool
+		purgeSource      bool
+		confirm          confirmer
+		wantErr          assert.ErrorAssertionFunc
+		wantSecrets      int
+		wantSecret       bool
+		wantSealedSecret bool
+	}{
+		{
+			name:             "remove",
+			source:           "secrets/secret.yaml",
+			confirm:          alwaysConfirm,
+			wantErr:          assert.NoError,
+			wantSecret:       true,
+			wantSealedSecret: true,
+		},
+		{
+			name:             "not found",
+			source:           "secrets/other-secret.yaml",
+			confirm:          alwaysConfirm,
+			wantErr:          assert.Error,
+			wantSecrets:      1,
+			wantSecret:       true,
+			wantSealedSecret: true,
+		},
+		{
+			name:        "purge",
+			source:      "secrets/secret.yaml",
+			purge:       true,
+			purgeSource: true,
+			confirm:     alwaysConfirm,
+			wantErr:     assert.NoError,
+		},
+		{
+			name:             "purge not confirmed",
+			source:           "secrets/secret.yaml",
+			purge:            true,
+			purgeSource:      true,
+			confirm:          func(string) bool { return false },
+			wantErr:          assert.NoError,
+			wantSecret:       true,
+			wantSealedSecret: true,
+		},
+	}
+
+	logger := slog.Default()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpdir := t.TempDir()
+			require.NoError(t, initFS(tmpdir))
+			secretFile := filepath.Join(tmpdir, "secrets", "secret.yaml")
+			sealedSecretFile := filepath.Join(tmpdir, "manifests", "sealed-secret.yaml")
+			require.NoError(t, os.WriteFile(secretFile, []byte("secret"), 0644))
+			require.NoError(t, os.WriteFile(sealedSecretFile, []byte("sealed-secret"), 0644))
+
+			v := viper.New()
+			v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+			v.Set("purge", tt.purge)
+			v.Set("purge-source", tt.purgeSource)
+
+			inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+			inv.Add(inventory.Secret{Source: "secret.yaml", Destination: "sealed-secret.yaml", Namespace: "default"})
+			var lock inventory.Lock
+			lock.SetDigest("secret.yaml", "1234")
+
+			err := removeFromInventory(&inv, &lock, filepath.Join(tmpdir, tt.source), v, tt.confirm, logger)
+			tt.wantErr(t, err)
+			assert.Len(t, inv.Secrets, tt.wantSecrets)
+			assert.Equal(t, tt.wantSecrets > 0, lock.Digest("secret.yaml") != "")
+
+			_, err = os.Stat(secretFile)
+			assert.Equal(t, tt.wantSecret, err == nil)
+			_, err = os.Stat(sealedSecretFile)
+			assert.Equal(t, tt.wantSealedSecret, err == nil)
+		})
+	}
+}
+
+func Test_removeFromInventory_clusters(t *testing.T) {
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+	secretFile := filepath.Join(tmpdir, "secrets", "secret.yaml")
+	require.NoError(t, os.WriteFile(secretFile, []byte("secret"), 0644))
+	for _, cluster := range []string{"staging", "production"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "manifests", cluster+"-secret.yaml"), []byte("sealed-secret"), 0644))
+	}
+
+	v := viper.New()
+	v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+	v.Set("purge", true)
+
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests", Clusters: []inventory.Cluster{{Name: "staging"}, {Name: "production"}}}
+	inv.Add(inventory.Secret{Source: "secret.yaml", Namespace: "default", Clusters: []inventory.Target{
+		{Cluster: "staging", Destination: "staging-secret.yaml"},
+		{Cluster: "production", Destination: "production-secret.yaml"},
+	}})
+	var lock inventory.Lock
+	lock.SetDigest("secret.yaml@staging", "1234")
+	lock.SetDigest("secret.yaml@production", "5678")
+	lock.SetDigest("other.yaml", "9012")
+
+	require.NoError(t, removeFromInventory(&inv, &lock, secretFile, v, alwaysConfirm, slog.Default()))
+	assert.Empty(t, inv.Secrets)
+	assert.Equal(t, map[string]string{"other.yaml": "9012"}, lock.Digests)
+	for _, cluster := range []string{"staging", "production"} {
+		assert.NoFileExists(t, filepath.Join(tmpdir, "manifests", cluster+"-secret.yaml"))
+	}
+
+	// a secret without a destination must not purge the destination directory
+	inv.Add(inventory.Secret{Source: "secret.yaml", Namespace: "default"})
+	assert.Error(t, removeFromInventory(&inv, &lock, secretFile, v, alwaysConfirm, slog.Default()))
+	assert.Len(t, inv.Secrets, 1)
+	assert.DirExists(t, filepath.Join(tmpdir, "manifests"))
+}
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"bytes"
+	"cmp"
 	"codeberg.org/clambin/go-common/charmer"
 	"context"
 	"crypto/rsa"
+	"errors"
 	"fmt"
 	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
 	"github.com/bitnami-labs/sealed-secrets/pkg/kubeseal"
@@ -15,118 +18,519 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
 )
 
 var (
+	controllerArgs = charmer.Arguments{
+		"controller-name":      {Default: "", Help: "Name of sealed-secrets controller (default: from the inventory, or " + defaultController + ")"},
+		"controller-namespace": {Default: "", Help: "Namespace of sealed-secrets controller (default: from the inventory, or " + defaultController + ")"},
+		"kubeconfig":           {Default: "", Help: "Path of the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)"},
+		"context":              {Default: "", Help: "Kube context to use (default: the kubeconfig's current context)"},
+	}
+
+	certArgs = charmer.Arguments{
+		"cert": {Default: "", Help: "Use the controller certificate in this PEM file, rather than fetching it from the controller"},
+	}
+
 	sealArgs = charmer.Arguments{
-		"controller-name":      {Default: "sealed-secrets", Help: "Name of sealed-secrets controller"},
-		"controller-namespace": {Default: "sealed-secrets", Help: "Namespace of sealed-secrets controller"},
-		"force":                {Default: false, Help: "Seal secrets even if the secret has not been updated"},
+		"force":        {Default: false, Help: "Seal secrets even if the secret has not been updated"},
+		"keep-going":   {Default: false, Help: "Continue sealing the remaining secrets if a secret fails to seal"},
+		"jobs":         {Default: 1, Help: "Number of secrets to seal in parallel"},
+		"dry-run":      {Default: false, Help: "Show which secrets would be sealed, without sealing them"},
+		"watch":        {Default: false, Help: "Keep running and reseal secrets when they change"},
+		"refresh-cert": {Default: false, Help: "Fetch the controller certificate, even if a cached certificate is available"},
+		"cert-max-age": {Default: 24 * time.Hour, Help: "How long to reuse a cached controller certificate (0: until the certificate expires)"},
 	}
 
 	sealCmd = &cobra.Command{
 		Use:   "seal",
 		Short: "Seal all secrets",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			inv, err := inventory.ReadFromFile(viper.GetString("inventory"))
+			if viper.GetBool("watch") && viper.GetBool("dry-run") {
+				return errors.New("--watch and --dry-run cannot be combined")
+			}
+			inventoryFile := viper.GetString("inventory")
+			inv, err := inventory.ReadFromFile(inventoryFile)
 			if err != nil {
 				return fmt.Errorf("unable to load ansible inventory file: %w", err)
 			}
-			s := newKubeSealer(viper.GetString("controller-namespace"), viper.GetString("controller-namespace"))
-			return seal(s, inv, viper.GetViper(), charmer.GetLogger(cmd))
+			lockFile := inventory.LockPath(inventoryFile)
+			lock, err := inventory.ReadLockFromFile(lockFile)
+			if err != nil {
+				return fmt.Errorf("unable to load lock file: %w", err)
+			}
+			makeSealers := func(inv inventory.Inventory) (sealers, error) { return dryRunSealers(inv), nil }
+			if !viper.GetBool("dry-run") {
+				cache, err := newCertCache(viper.GetDuration("cert-max-age"))
+				if err != nil {
+					charmer.GetLogger(cmd).Warn("controller certificates will not be cached", "err", err)
+				}
+				makeSealers = func(inv inventory.Inventory) (sealers, error) { return newSealers(inv, cache, viper.GetViper()) }
+			}
+			s, err := makeSealers(inv)
+			if err != nil {
+				return err
+			}
+			err = seal(s, inv, lock, viper.GetViper(), charmer.GetLogger(cmd))
+			if viper.GetBool("dry-run") {
+				return err
+			}
+			// record the digests of all secrets sealed so far, even if sealing failed for some
+			if lockErr := lock.WriteToFile(lockFile); lockErr != nil {
+				err = errors.Join(err, fmt.Errorf("unable to write lock file: %w", lockErr))
+			}
+			if err != nil || !viper.GetBool("watch") {
+				return err
+			}
+			ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+			return watch(ctx, s, makeSealers, inventoryFile, inv, lock, viper.GetViper(), charmer.GetLogger(cmd))
 		},
 	}
 )
 
 // sealer interface so we can stub during unit testing
 type sealer interface {
-	seal(w io.Writer, r io.Reader, namespace string) error
+	seal(w io.Writer, r io.Reader, namespace string, scope v1alpha1.SealingScope) error
+}
+
+// sealers holds the sealer for each cluster in the inventory. The sealer for the default cluster has no name.
+type sealers map[string]sealer
+
+// newSealers returns a sealer for the inventory's default controller and for each of its clusters.
+func newSealers(inv inventory.Inventory, cache *certCache, v *viper.Viper) (sealers, error) {
+	kubeSealers, err := newKubeSealers(inv, cache, v)
+	if err != nil {
+		return nil, err
+	}
+	s := make(sealers, len(kubeSealers))
+	for name, ks := range kubeSealers {
+		s[name] = ks
+	}
+	return s, nil
+}
+
+// newKubeSealers returns a kubeSealer for the inventory's default controller and for each of its clusters. The
+// kubeSealer for the default cluster has no name. It fails if the inventory's clusters are invalid.
+func newKubeSealers(inv inventory.Inventory, cache *certCache, v *viper.Viper) (map[string]*kubeSealer, error) {
+	if err := errors.Join(validateClusters(inv)...); err != nil {
+		return nil, fmt.Errorf("invalid clusters: %w", err)
+	}
+	cfg := controllerSealerConfig(inv, v)
+	cfg.certFile = certFile(inv, v)
+	cfg.certFingerprint = inv.CertFingerprint
+	cfg.certCache = cache
+	cfg.refreshCert = v.GetBool("refresh-cert")
+	s := map[string]*kubeSealer{"": newKubeSealer(cfg)}
+	for _, cluster := range inv.Clusters {
+		s[cluster.Name] = newKubeSealer(clusterSealerConfig(inv, cluster, cache, v))
+	}
+	return s, nil
+}
+
+// dryRunSealers returns a dryRunSealer for each cluster in the inventory.
+func dryRunSealers(inv inventory.Inventory) sealers {
+	s := sealers{"": dryRunSealer{}}
+	for _, cluster := range inv.Clusters {
+		s[cluster.Name] = dryRunSealer{}
+	}
+	return s
+}
+
+// defaultController is the name and namespace of the sealed-secrets controller, if neither the command line nor the
+// inventory specify them.
+const defaultController = "sealed-secrets"
+
+// controllerSealerConfig returns the configuration to contact the inventory's default controller. The command line
+// takes precedence over the inventory.
+func controllerSealerConfig(inv inventory.Inventory, v *viper.Viper) kubeSealerConfig {
+	return kubeSealerConfig{
+		kubeconfig:          v.GetString("kubeconfig"),
+		kubeContext:         v.GetString("context"),
+		expectedContext:     inv.Context,
+		controllerNamespace: cmp.Or(v.GetString("controller-namespace"), inv.ControllerNamespace, defaultController),
+		controllerName:      cmp.Or(v.GetString("controller-name"), inv.ControllerName, defaultController),
+	}
 }
 
-func seal(s sealer, inv inventory.Inventory, v *viper.Viper, l *slog.Logger) error {
-	for _, secret := range inv.Secrets {
-		if err := maybeSeal(s, inv, secret, v, l.With("secret", secret.Source)); err != nil {
-			return fmt.Errorf("failed to seal %q: %w", secret.Source, err)
+// clusterSealerConfig returns the configuration to seal secrets for the cluster. Any context or controller setting
+// the cluster doesn't specify falls back to the inventory's default controller.
+func clusterSealerConfig(inv inventory.Inventory, cluster inventory.Cluster, cache *certCache, v *viper.Viper) kubeSealerConfig {
+	defaults := controllerSealerConfig(inv, v)
+	cfg := kubeSealerConfig{
+		kubeconfig:          defaults.kubeconfig,
+		kubeContext:         cmp.Or(cluster.Context, defaults.kubeContext),
+		expectedContext:     cmp.Or(cluster.Context, defaults.expectedContext),
+		controllerNamespace: cmp.Or(cluster.ControllerNamespace, defaults.controllerNamespace),
+		controllerName:      cmp.Or(cluster.ControllerName, defaults.controllerName),
+		certFingerprint:     cluster.CertFingerprint,
+		certCache:           cache,
+		refreshCert:         v.GetBool("refresh-cert"),
+	}
+	if cluster.Cert != "" {
+		cfg.certFile = filepath.Join(v.GetString("ansible"), cluster.Cert)
+	}
+	return cfg
+}
+
+// certFile returns the path of the controller certificate to seal with. The command line takes precedence over the
+// inventory. If neither is set, it returns an empty string and the certificate is fetched from the controller.
+func certFile(inv inventory.Inventory, v *viper.Viper) string {
+	if cert := v.GetString("cert"); cert != "" {
+		return cert
+	}
+	if inv.Cert != "" {
+		return filepath.Join(v.GetString("ansible"), inv.Cert)
+	}
+	return ""
+}
+
+// sealJob seals a secret for one of the clusters it targets.
+type sealJob struct {
+	secret inventory.Secret
+	target inventory.Target
+}
+
+// name identifies the job in logs and errors.
+func (j sealJob) name() string {
+	if j.target.Cluster == "" {
+		return j.secret.Source
+	}
+	return j.secret.Source + "@" + j.target.Cluster
+}
+
+// targetSecret returns the secret, with the destination for the job's cluster.
+func (j sealJob) targetSecret() inventory.Secret {
+	secret := j.secret
+	secret.Destination = j.target.Destination
+	return secret
+}
+
+// lockKey returns the key of the secret's digest in the lock file. Each cluster has its own digest, so a secret that
+// failed to seal for one cluster is still resealed for that cluster on the next run.
+func (j sealJob) lockKey() string {
+	if j.target.Cluster == "" {
+		return j.secret.Source
+	}
+	return j.secret.Source + "@" + j.target.Cluster
+}
+
+// logger returns l, with the job's cluster if it has one.
+func (j sealJob) logger(l *slog.Logger) *slog.Logger {
+	if j.target.Cluster == "" {
+		return l
+	}
+	return l.With("cluster", j.target.Cluster)
+}
+
+// sealJobs returns a job for each cluster targeted by the secrets.
+func sealJobs(secrets []inventory.Secret) []sealJob {
+	var jobs []sealJob
+	for _, secret := range secrets {
+		for _, target := range secret.Targets() {
+			jobs = append(jobs, sealJob{secret: secret, target: target})
 		}
 	}
-	return nil
+	return jobs
 }
 
-func maybeSeal(s sealer, inv inventory.Inventory, secret inventory.Secret, v *viper.Viper, l *slog.Logger) error {
-	ansibleDir := v.GetString("ansible")
+func seal(s sealers, inv inventory.Inventory, lock *inventory.Lock, v *viper.Viper, l *slog.Logger) error {
+	keepGoing := v.GetBool("keep-going")
+	jobs := max(v.GetInt("jobs"), 1)
+
+	var (
+		mu                         sync.Mutex
+		errs                       []error
+		sealed, wouldSeal, skipped int
+		wg                         sync.WaitGroup
+	)
+	queue := make(chan sealJob)
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				ok, err := maybeSeal(s, inv, lock, job, v, l.With("secret", job.name()))
+				mu.Lock()
+				switch {
+				case err != nil:
+					err = fmt.Errorf("failed to seal %q: %w", job.name(), err)
+					if keepGoing {
+						l.Error("failed to seal secret", "secret", job.name(), "err", err)
+					}
+					errs = append(errs, err)
+				case ok && isDryRun(s[job.target.Cluster]):
+					wouldSeal++
+				case ok:
+					sealed++
+				default:
+					skipped++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, job := range sealJobs(inv.Secrets) {
+		mu.Lock()
+		stop := !keepGoing && len(errs) > 0
+		mu.Unlock()
+		if stop {
+			break
+		}
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	if keepGoing {
+		if wouldSeal > 0 {
+			l.Info("sealing complete", "would seal", wouldSeal, "skipped", skipped, "failed", len(errs))
+		} else {
+			l.Info("sealing complete", "sealed", sealed, "skipped", skipped, "failed", len(errs))
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	secretFile := filepath.Join(ansibleDir, inv.SecretsDir, secret.Source)
-	sealedSecretFile := filepath.Join(ansibleDir, inv.DestinationDir, secret.Destination)
+// maybeSeal seals the secret for the job's cluster if it has changed since it was last sealed. It returns true if the
+// secret was sealed. A dryRunSealer doesn't write the sealed secret or record its digest.
+func maybeSeal(s sealers, inv inventory.Inventory, lock *inventory.Lock, job sealJob, v *viper.Viper, l *slog.Logger) (bool, error) {
+	secret := job.targetSecret()
+	secretFile, sealedSecretFile := secretPaths(inv, secret, v)
+	clusterSealer, ok := s[job.target.Cluster]
+	if !ok {
+		return false, fmt.Errorf("unknown cluster %q", job.target.Cluster)
+	}
 
+	reason := forced
 	if !v.GetBool("force") {
-		update, err := shouldUpdate(secretFile, sealedSecretFile)
-		if err != nil {
-			return err
+		var err error
+		if reason, err = updateReason(secretFile, sealedSecretFile, lock.Digest(job.lockKey())); err != nil {
+			return false, err
 		}
-		if !update {
+		if reason == upToDate {
 			l.Debug("secret is already sealed")
-			return nil
+			return false, nil
 		}
 	}
 
-	l.Info("sealing secret")
+	// check each secret in the source
+	if err := validateSource(secretFile, secret.Namespace); err != nil {
+		return false, err
+	}
 
 	var fIn *os.File
 	var err error
 	if fIn, err = os.Open(secretFile); err != nil {
-		return fmt.Errorf("unable to open secret: %w", err)
+		return false, fmt.Errorf("unable to open secret: %w", err)
 	}
 	defer func(f *os.File) { _ = f.Close() }(fIn)
 
-	var fOut *os.File
-	if fOut, err = os.OpenFile(sealedSecretFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
-		return fmt.Errorf("unable to open sealed secret: %w", err)
+	var scope v1alpha1.SealingScope
+	if err = scope.Set(inv.SecretScope(secret)); err != nil {
+		return false, fmt.Errorf("invalid scope: %w", err)
 	}
-	defer func(f *os.File) { _ = f.Close() }(fOut)
 
-	err = s.seal(fOut, fIn, secret.Namespace)
+	if isDryRun(clusterSealer) {
+		l.Info("secret would be sealed", "reason", reason)
+		return true, clusterSealer.seal(io.Discard, fIn, secret.Namespace, scope)
+	}
+
+	l.Info("sealing secret", "reason", reason)
+
+	err = writeFileAtomic(sealedSecretFile, 0644, func(w io.Writer) error {
+		return clusterSealer.seal(w, fIn, secret.Namespace, scope)
+	})
 	l.Debug("kubeseal result", "err", err)
-	return err
+	if err != nil {
+		return false, err
+	}
+
+	digest, err := digestFile(secretFile)
+	if err != nil {
+		return false, fmt.Errorf("unable to compute digest: %w", err)
+	}
+	lock.SetDigest(job.lockKey(), digest)
+	return true, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var _ sealer = dryRunSealer{}
+
+// dryRunSealer is used in dry-run mode, so the controller is never contacted. It doesn't seal anything.
+type dryRunSealer struct{}
+
+func (dryRunSealer) seal(io.Writer, io.Reader, string, v1alpha1.SealingScope) error {
+	return nil
+}
+
+// isDryRun returns true if s only reports which secrets would be sealed.
+func isDryRun(s sealer) bool {
+	_, ok := s.(dryRunSealer)
+	return ok
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 type kubeSealer struct {
-	clientConfig        kubeseal.ClientConfig
+	kubeSealerConfig
+	clientConfig kubeseal.ClientConfig
+	lock         sync.Mutex
+	publicKey    *rsa.PublicKey
+}
+
+type kubeSealerConfig struct {
+	// kubeconfig is the kubeconfig file. If empty, the default loading rules apply.
+	kubeconfig string
+	// kubeContext is the kube context used to contact the controller. If empty, the current context is used.
+	kubeContext string
+	// expectedContext is the kube context the inventory expects. If set, the controller is not contacted through
+	// any other context.
+	expectedContext     string
 	controllerNamespace string
 	controllerName      string
-	publicKey           *rsa.PublicKey
+	// certFile is the controller's certificate. If set, the certificate is read from this file and the controller is not contacted.
+	certFile string
+	// certFingerprint is the expected SHA-256 fingerprint of the controller's certificate. If set, sealing fails if the
+	// certificate doesn't match.
+	certFingerprint string
+	// certCache caches the certificates fetched from the controller. If nil, the certificate is always fetched.
+	certCache *certCache
+	// refreshCert fetches the certificate from the controller, even if the cache holds a valid certificate.
+	refreshCert bool
 }
 
-func newKubeSealer(controllerNamespace, controllerName string) *kubeSealer {
+// newKubeSealer returns a sealer that seals secrets with the certificate of the sealed-secrets controller.
+func newKubeSealer(cfg kubeSealerConfig) *kubeSealer {
 	return &kubeSealer{
-		clientConfig:        initClient(),
-		controllerNamespace: controllerNamespace,
-		controllerName:      controllerName,
+		kubeSealerConfig: cfg,
+		clientConfig:     initClient(cfg.kubeconfig, cfg.kubeContext),
 	}
 }
 
-func initClient() clientcmd.ClientConfig {
+func initClient(kubeconfig, kubeContext string) clientcmd.ClientConfig {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	loadingRules.DefaultClientConfig = &clientcmd.DefaultClientConfig
-	return clientcmd.NewInteractiveDeferredLoadingClientConfig(loadingRules, nil, nil)
+	loadingRules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	return clientcmd.NewInteractiveDeferredLoadingClientConfig(loadingRules, overrides, nil)
 }
 
-func (s *kubeSealer) getPublicKey() error {
-	r, err := kubeseal.OpenCert(context.Background(), s.clientConfig, s.controllerName, s.controllerNamespace, "")
-	if err == nil {
-		s.publicKey, err = kubeseal.ParseKey(r)
-		_ = r.Close()
+// getPublicKey returns the controller's public key. The key is only fetched once, even when called concurrently.
+func (s *kubeSealer) getPublicKey() (*rsa.PublicKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.publicKey != nil {
+		return s.publicKey, nil
+	}
+	cert, fetched, err := s.loadCert()
+	if err != nil {
+		return nil, err
+	}
+	if s.certFingerprint != "" {
+		fingerprint, err := certFingerprint(cert)
+		if err != nil {
+			return nil, err
+		}
+		if !sameFingerprint(fingerprint, s.certFingerprint) {
+			return nil, fmt.Errorf("controller certificate fingerprint %s doesn't match pinned fingerprint %s", fingerprint, s.certFingerprint)
+		}
 	}
-	return err
+	if s.publicKey, err = kubeseal.ParseKey(bytes.NewReader(cert)); err != nil {
+		return nil, err
+	}
+	// only cache certificates that passed the checks above, so a certificate from the wrong controller isn't reused
+	if fetched && s.certCache != nil {
+		// failing to cache the certificate only means we fetch it again next time
+		_ = s.certCache.put(s.certCacheKey(), cert)
+	}
+	return s.publicKey, nil
+}
+
+// getCert returns the controller's PEM-encoded certificate.
+func (s *kubeSealer) getCert() ([]byte, error) {
+	cert, _, err := s.loadCert()
+	return cert, err
+}
+
+// loadCert returns the controller's PEM-encoded certificate, from the certificate file, the cache or the controller.
+// fetched is true if the certificate was fetched from the controller. It is not cached: that is up to the caller.
+func (s *kubeSealer) loadCert() (cert []byte, fetched bool, err error) {
+	if s.certFile != "" {
+		cert, err = os.ReadFile(s.certFile)
+		return cert, false, err
+	}
+	if err = s.checkContext(); err != nil {
+		return nil, false, err
+	}
+	if s.certCache != nil && !s.refreshCert {
+		if cert, ok := s.certCache.get(s.certCacheKey()); ok {
+			return cert, false, nil
+		}
+	}
+	r, err := kubeseal.OpenCert(context.Background(), s.clientConfig, s.controllerNamespace, s.controllerName, "")
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = r.Close() }()
+	cert, err = io.ReadAll(r)
+	return cert, err == nil, err
 }
 
-func (s *kubeSealer) seal(w io.Writer, r io.Reader, namespace string) error {
-	if s.publicKey == nil {
-		if err := s.getPublicKey(); err != nil {
-			return err
+func (s *kubeSealer) certCacheKey() certCacheKey {
+	return certCacheKey{context: s.contextName(), namespace: s.controllerNamespace, name: s.controllerName}
+}
+
+// contextName returns the name of the kube context used to contact the controller.
+func (s *kubeSealer) contextName() string {
+	if s.kubeContext != "" {
+		return s.kubeContext
+	}
+	if cc, ok := s.clientConfig.(clientcmd.ClientConfig); ok {
+		if raw, err := cc.RawConfig(); err == nil {
+			return raw.CurrentContext
 		}
 	}
-	return kubeseal.Seal(s.clientConfig, "yaml", r, w, scheme.Codecs, s.publicKey, v1alpha1.DefaultScope, true, "", namespace)
+	return ""
+}
+
+// checkContext returns an error if the controller would be contacted through a different kube context than the one
+// the inventory expects.
+func (s *kubeSealer) checkContext() error {
+	if s.expectedContext == "" {
+		return nil
+	}
+	if name := s.contextName(); name != s.expectedContext {
+		return fmt.Errorf("kube context %q doesn't match the inventory's context %q", name, s.expectedContext)
+	}
+	return nil
+}
+
+func (s *kubeSealer) seal(w io.Writer, r io.Reader, namespace string, scope v1alpha1.SealingScope) error {
+	publicKey, err := s.getPublicKey()
+	if err != nil {
+		return err
+	}
+	return kubeseal.Seal(s.clientConfig, "yaml", r, w, scheme.Codecs, publicKey, scope, true, "", namespace)
+}
+
+func (s *kubeSealer) verify(r io.Reader) error {
+	if err := s.checkContext(); err != nil {
+		return err
+	}
+	return kubeseal.ValidateSealedSecret(context.Background(), s.clientConfig, s.controllerNamespace, s.controllerName, r)
+}
+
+func (s *kubeSealer) reencrypt(w io.Writer, r io.Reader) error {
+	if err := s.checkContext(); err != nil {
+		return err
+	}
+	return kubeseal.ReEncryptSealedSecret(context.Background(), s.clientConfig, s.controllerNamespace, s.controllerName, "yaml", r, w, scheme.Codecs)
 }
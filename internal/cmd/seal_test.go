@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
 	ssv1alpha1 "github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
 	"github.com/bitnami-labs/sealed-secrets/pkg/kubeseal"
+	"github.com/clambin/seals/internal/clilogger"
 	"github.com/clambin/seals/internal/inventory"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -14,8 +16,10 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func Test_seal(t *testing.T) {
@@ -26,7 +30,7 @@ func Test_seal(t *testing.T) {
 	v.Set("controller-name", "seal-secrets")
 	v.Set("controller-namespace", "seal-secrets")
 
-	const body = "test"
+	body := secretYAML("test", "default")
 	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "test"), []byte(body), 0644))
 	var inv inventory.Inventory
 	inv.SecretsDir = "."
@@ -34,22 +38,379 @@ func Test_seal(t *testing.T) {
 	inv.Add(inventory.Secret{Source: "test", Destination: "sealed-test", Namespace: "default"})
 
 	var s fakeSealer
-	assert.NoError(t, seal(s, inv, v, slog.Default()))
+	var lock inventory.Lock
+	assert.NoError(t, seal(sealers{"": s}, inv, &lock, v, slog.Default()))
 
 	result, err := os.ReadFile(filepath.Join(tmpdir, "sealed-test"))
 	require.NoError(t, err)
 	assert.Equal(t, body, string(result))
+	assert.NotEmpty(t, lock.Digest("test"))
+
+	// source is unchanged: mtime says update, but the digest says no
+	require.NoError(t, os.Chtimes(filepath.Join(tmpdir, "sealed-test"), time.Now(), time.Now().Add(-time.Hour)))
+	assert.NoError(t, seal(sealers{"": failingSealer{}}, inv, &lock, v, slog.Default()))
+
+	// source has changed
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "test"), []byte(secretYAML("updated", "default")), 0644))
+	assert.Error(t, seal(sealers{"": failingSealer{}}, inv, &lock, v, slog.Default()))
+}
+
+func Test_seal_failure(t *testing.T) {
+	tmpdir := t.TempDir()
+
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+	v.Set("force", true)
+
+	const sealed = "previously sealed"
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "test"), []byte(secretYAML("test", "default")), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "sealed-test"), []byte(sealed), 0644))
+	var inv inventory.Inventory
+	inv.SecretsDir = "."
+	inv.DestinationDir = "."
+	inv.Add(inventory.Secret{Source: "test", Destination: "sealed-test", Namespace: "default"})
+
+	assert.Error(t, seal(sealers{"": failingSealer{}}, inv, &inventory.Lock{}, v, slog.Default()))
+
+	result, err := os.ReadFile(filepath.Join(tmpdir, "sealed-test"))
+	require.NoError(t, err)
+	assert.Equal(t, sealed, string(result))
+
+	entries, err := os.ReadDir(tmpdir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func Test_seal_namespaceMismatch(t *testing.T) {
+	tmpdir := t.TempDir()
+
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "test"), []byte(secretYAML("foo", "default")+"---\n"+secretYAML("bar", "other")), 0644))
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: "."}
+	inv.Add(inventory.Secret{Source: "test", Destination: "sealed-test", Namespace: "default"})
+
+	assert.Error(t, seal(sealers{"": fakeSealer{}}, inv, &inventory.Lock{}, v, slog.Default()))
+	assert.NoFileExists(t, filepath.Join(tmpdir, "sealed-test"))
+}
+
+func Test_seal_keepGoing(t *testing.T) {
+	tests := []struct {
+		name       string
+		keepGoing  bool
+		wantSealed bool
+	}{
+		{name: "stop on first error", keepGoing: false, wantSealed: false},
+		{name: "keep going", keepGoing: true, wantSealed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpdir := t.TempDir()
+
+			v := viper.New()
+			v.Set("ansible", tmpdir)
+			v.Set("keep-going", tt.keepGoing)
+
+			require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "test"), []byte(secretYAML("test", "default")), 0644))
+			inv := inventory.Inventory{SecretsDir: ".", DestinationDir: "."}
+			inv.Add(inventory.Secret{Source: "missing-1", Destination: "sealed-missing-1", Namespace: "default"})
+			inv.Add(inventory.Secret{Source: "test", Destination: "sealed-test", Namespace: "default"})
+			inv.Add(inventory.Secret{Source: "missing-2", Destination: "sealed-missing-2", Namespace: "default"})
+
+			err := seal(sealers{"": fakeSealer{}}, inv, &inventory.Lock{}, v, slog.Default())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), `failed to seal "missing-1"`)
+			assert.Equal(t, tt.keepGoing, strings.Contains(err.Error(), `failed to seal "missing-2"`))
+
+			_, err = os.Stat(filepath.Join(tmpdir, "sealed-test"))
+			assert.Equal(t, tt.wantSealed, err == nil)
+		})
+	}
+}
+
+func Test_seal_jobs(t *testing.T) {
+	tmpdir := t.TempDir()
+
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+	v.Set("jobs", 4)
+
+	certFile := filepath.Join(tmpdir, "cert.pem")
+	require.NoError(t, os.WriteFile(certFile, []byte(testCert), 0644))
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: "."}
+	for i := range 10 {
+		source := "secret-" + strconv.Itoa(i)
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, source), []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: `+source+`
+stringData:
+  PASS: "1234"
+`), 0644))
+		inv.Add(inventory.Secret{Source: source, Destination: "sealed-" + source, Namespace: "default"})
+	}
+
+	var lock inventory.Lock
+	require.NoError(t, seal(sealers{"": newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets", certFile: certFile})}, inv, &lock, v, slog.Default()))
+	for _, secret := range inv.Secrets {
+		assert.FileExists(t, filepath.Join(tmpdir, secret.Destination))
+		assert.NotEmpty(t, lock.Digest(secret.Source))
+	}
+}
+
+func Test_seal_dryRun(t *testing.T) {
+	tmpdir := t.TempDir()
+
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "new"), []byte(secretYAML("new", "default")), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "sealed-current"), []byte("sealed"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "current"), []byte(secretYAML("current", "default")), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(tmpdir, "current"), time.Now(), time.Now().Add(-time.Hour)))
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: "."}
+	inv.Add(inventory.Secret{Source: "new", Destination: "sealed-new", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "current", Destination: "sealed-current", Namespace: "default"})
+
+	var out bytes.Buffer
+	l := slog.New(clilogger.NewHandler(&out, slog.LevelInfo))
+	var lock inventory.Lock
+	require.NoError(t, seal(sealers{"": dryRunSealer{}}, inv, &lock, v, l))
+	assert.Equal(t, "INFO secret would be sealed (secret=new, reason=destination missing)\n", out.String())
+	assert.NoFileExists(t, filepath.Join(tmpdir, "sealed-new"))
+	assert.Empty(t, lock.Digests)
+
+	// the summary reports secrets that would be sealed
+	out.Reset()
+	v.Set("keep-going", true)
+	require.NoError(t, seal(sealers{"": dryRunSealer{}}, inv, &lock, v, l))
+	assert.Contains(t, out.String(), "INFO sealing complete (would seal=1, skipped=1, failed=0)\n")
+	v.Set("keep-going", false)
+	assert.NoFileExists(t, filepath.Join(tmpdir, "sealed-new"))
+	assert.Empty(t, lock.Digests)
+
+	out.Reset()
+	v.Set("force", true)
+	require.NoError(t, seal(sealers{"": dryRunSealer{}}, inv, &lock, v, l))
+	assert.Equal(t, `INFO secret would be sealed (secret=new, reason=forced)
+INFO secret would be sealed (secret=current, reason=forced)
+`, out.String())
+	content, err := os.ReadFile(filepath.Join(tmpdir, "sealed-current"))
+	require.NoError(t, err)
+	assert.Equal(t, "sealed", string(content))
+}
+
+func Test_seal_scope(t *testing.T) {
+	tmpdir := t.TempDir()
+
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+	v.Set("force", true)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "test-1"), []byte(secretYAML("test-1", "default")), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "test-2"), []byte(secretYAML("test-2", "default")), 0644))
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: ".", Scope: "namespace-wide"}
+	inv.Add(inventory.Secret{Source: "test-1", Destination: "sealed-test-1", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "test-2", Destination: "sealed-test-2", Namespace: "default", Scope: "cluster-wide"})
+
+	var s scopeSealer
+	require.NoError(t, seal(sealers{"": &s}, inv, &inventory.Lock{}, v, slog.Default()))
+	assert.Equal(t, []ssv1alpha1.SealingScope{ssv1alpha1.NamespaceWideScope, ssv1alpha1.ClusterWideScope}, s.scopes)
+
+	inv.Secrets[0].Scope = "invalid"
+	assert.Error(t, seal(sealers{"": &s}, inv, &inventory.Lock{}, v, slog.Default()))
+}
+
+func Test_seal_clusters(t *testing.T) {
+	tmpdir := t.TempDir()
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "test"), []byte(secretYAML("test", "default")), 0644))
+	inv := inventory.Inventory{
+		SecretsDir:     ".",
+		DestinationDir: ".",
+		Clusters:       []inventory.Cluster{{Name: "staging"}, {Name: "production"}},
+	}
+	inv.Add(inventory.Secret{Source: "test", Namespace: "default", Clusters: []inventory.Target{
+		{Cluster: "staging", Destination: "staging-test"},
+		{Cluster: "production", Destination: "production-test"},
+	}})
+
+	s := sealers{"staging": clusterSealer("staging"), "production": clusterSealer("production")}
+	var lock inventory.Lock
+	require.NoError(t, seal(s, inv, &lock, v, slog.Default()))
+	for _, cluster := range []string{"staging", "production"} {
+		result, err := os.ReadFile(filepath.Join(tmpdir, cluster+"-test"))
+		require.NoError(t, err)
+		assert.Equal(t, cluster, string(result))
+		assert.NotEmpty(t, lock.Digest("test@"+cluster))
+	}
+
+	// a cluster without a sealer fails
+	v.Set("force", true)
+	delete(s, "production")
+	assert.ErrorContains(t, seal(s, inv, &lock, v, slog.Default()), `unknown cluster "production"`)
+}
+
+func Test_controllerSealerConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		inv           inventory.Inventory
+		flags         map[string]string
+		wantName      string
+		wantNamespace string
+	}{
+		{
+			name:          "defaults",
+			wantName:      "sealed-secrets",
+			wantNamespace: "sealed-secrets",
+		},
+		{
+			name:          "inventory",
+			inv:           inventory.Inventory{ControllerName: "controller", ControllerNamespace: "kube-system"},
+			wantName:      "controller",
+			wantNamespace: "kube-system",
+		},
+		{
+			name:          "flags override inventory",
+			inv:           inventory.Inventory{ControllerName: "controller", ControllerNamespace: "kube-system"},
+			flags:         map[string]string{"controller-name": "other-controller", "controller-namespace": "other-namespace"},
+			wantName:      "other-controller",
+			wantNamespace: "other-namespace",
+		},
+		{
+			name:          "name and namespace are set independently",
+			inv:           inventory.Inventory{ControllerNamespace: "kube-system"},
+			flags:         map[string]string{"controller-name": "other-controller"},
+			wantName:      "other-controller",
+			wantNamespace: "kube-system",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			for key, value := range tt.flags {
+				v.Set(key, value)
+			}
+			cfg := controllerSealerConfig(tt.inv, v)
+			assert.Equal(t, tt.wantName, cfg.controllerName)
+			assert.Equal(t, tt.wantNamespace, cfg.controllerNamespace)
+		})
+	}
+}
+
+func Test_newSealers(t *testing.T) {
+	v := viper.New()
+	v.Set("ansible", "ansible")
+	v.Set("controller-name", "controller")
+	inv := inventory.Inventory{
+		ControllerNamespace: "kube-system",
+		Cert:                "cert.pem",
+		CertFingerprint:     "abcd",
+		Clusters:            []inventory.Cluster{{Name: "staging", ControllerName: "staging-controller"}},
+	}
+
+	s, err := newSealers(inv, nil, v)
+	require.NoError(t, err)
+	require.Len(t, s, 2)
+	ks := s[""].(*kubeSealer)
+	assert.Equal(t, "controller", ks.controllerName)
+	assert.Equal(t, "kube-system", ks.controllerNamespace)
+	assert.Equal(t, filepath.Join("ansible", "cert.pem"), ks.certFile)
+	assert.Equal(t, "abcd", ks.certFingerprint)
+	ks = s["staging"].(*kubeSealer)
+	assert.Equal(t, "staging-controller", ks.controllerName)
+	assert.Equal(t, "kube-system", ks.controllerNamespace)
+	assert.Empty(t, ks.certFile)
+	assert.Empty(t, ks.certFingerprint)
+
+	// invalid clusters
+	inv.Clusters = append(inv.Clusters, inventory.Cluster{Name: "staging"})
+	_, err = newSealers(inv, nil, v)
+	assert.Error(t, err)
+}
+
+func TestKubeSeal_ControllerName(t *testing.T) {
+	ks := newKubeSealer(kubeSealerConfig{controllerNamespace: "kube-system", controllerName: "controller"})
+	ks.clientConfig = fakeController{cert: testCert, name: "controller", namespace: "kube-system"}.start(t)
+	cert, err := ks.getCert()
+	require.NoError(t, err)
+	assert.Equal(t, testCert, string(cert))
+
+	// name and namespace are not interchangeable
+	ks = newKubeSealer(kubeSealerConfig{controllerNamespace: "controller", controllerName: "kube-system"})
+	ks.clientConfig = fakeController{cert: testCert, name: "controller", namespace: "kube-system"}.start(t)
+	_, err = ks.getCert()
+	assert.Error(t, err)
+}
+
+func Test_clusterSealerConfig(t *testing.T) {
+	v := viper.New()
+	v.Set("ansible", "ansible")
+	v.Set("controller-name", "sealed-secrets")
+	inv := inventory.Inventory{ControllerNamespace: "kube-system"}
+
+	cfg := clusterSealerConfig(inv, inventory.Cluster{Name: "staging", Context: "staging-admin"}, nil, v)
+	assert.Equal(t, kubeSealerConfig{kubeContext: "staging-admin", expectedContext: "staging-admin", controllerName: "sealed-secrets", controllerNamespace: "kube-system"}, cfg)
+
+	// clusters without a context use the command line's context, and the inventory's expected context
+	inv.Context = "admin"
+	v.Set("context", "other-admin")
+	cfg = clusterSealerConfig(inv, inventory.Cluster{Name: "staging"}, nil, v)
+	assert.Equal(t, "other-admin", cfg.kubeContext)
+	assert.Equal(t, "admin", cfg.expectedContext)
+	v.Set("context", "")
+	inv.Context = ""
+
+	cfg = clusterSealerConfig(inv, inventory.Cluster{Name: "production", ControllerName: "controller", ControllerNamespace: "controllers", Cert: "production.pem", CertFingerprint: "abcd"}, nil, v)
+	assert.Equal(t, kubeSealerConfig{controllerName: "controller", controllerNamespace: "controllers", certFile: filepath.Join("ansible", "production.pem"), certFingerprint: "abcd"}, cfg)
+}
+
+func secretYAML(name, namespace string) string {
+	return "kind: Secret\nmetadata:\n  name: " + name + "\n  namespace: " + namespace + "\n"
 }
 
 var _ sealer = fakeSealer{}
 
 type fakeSealer struct{}
 
-func (f fakeSealer) seal(w io.Writer, r io.Reader, _ string) error {
+func (f fakeSealer) seal(w io.Writer, r io.Reader, _ string, _ ssv1alpha1.SealingScope) error {
 	_, err := io.Copy(w, r)
 	return err
 }
 
+var _ sealer = &scopeSealer{}
+
+type scopeSealer struct {
+	scopes []ssv1alpha1.SealingScope
+}
+
+func (s *scopeSealer) seal(_ io.Writer, _ io.Reader, _ string, scope ssv1alpha1.SealingScope) error {
+	s.scopes = append(s.scopes, scope)
+	return nil
+}
+
+var _ sealer = clusterSealer("")
+
+// clusterSealer writes the name of the cluster it seals for.
+type clusterSealer string
+
+func (c clusterSealer) seal(w io.Writer, _ io.Reader, _ string, _ ssv1alpha1.SealingScope) error {
+	_, err := w.Write([]byte(c))
+	return err
+}
+
+var _ sealer = failingSealer{}
+
+type failingSealer struct{}
+
+func (f failingSealer) seal(w io.Writer, _ io.Reader, _ string, _ ssv1alpha1.SealingScope) error {
+	_, _ = w.Write([]byte("partial"))
+	return errors.New("failed to seal")
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 const testCert = `
@@ -83,8 +444,29 @@ R9bIjJLIvp7CQPDkdRzJSjvetrKtI0l97VjsjbRB9v6ZekGY9SFI49KzKUTk8fsF
 -----END CERTIFICATE-----
 `
 
+func Test_certFile(t *testing.T) {
+	tests := []struct {
+		name string
+		cert string
+		inv  inventory.Inventory
+		want string
+	}{
+		{name: "none", want: ""},
+		{name: "inventory", inv: inventory.Inventory{Cert: "cert.pem"}, want: "/ansible/cert.pem"},
+		{name: "command line", cert: "/tmp/cert.pem", inv: inventory.Inventory{Cert: "cert.pem"}, want: "/tmp/cert.pem"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.Set("ansible", "/ansible")
+			v.Set("cert", tt.cert)
+			assert.Equal(t, tt.want, certFile(tt.inv, v))
+		})
+	}
+}
+
 func TestKubeSeal(t *testing.T) {
-	ks := newKubeSealer("sealed-secrets", "sealed-secret")
+	ks := newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secret"})
 	var err error
 	ks.publicKey, err = kubeseal.ParseKey(strings.NewReader(testCert))
 	assert.NoError(t, err)
@@ -100,7 +482,7 @@ stringData:
   PASS: "1234"
 `
 
-	err = ks.seal(&output, strings.NewReader(mySecret), "my-namespace")
+	err = ks.seal(&output, strings.NewReader(mySecret), "my-namespace", ssv1alpha1.DefaultScope)
 	assert.NoError(t, err)
 
 	var sealedSecret ssv1alpha1.SealedSecret
@@ -110,4 +492,155 @@ stringData:
 	assert.Equal(t, "my-namespace", sealedSecret.GetNamespace())
 	assert.Contains(t, sealedSecret.Spec.EncryptedData, "PASS")
 	assert.NotEqual(t, "1234", sealedSecret.Spec.EncryptedData)
+	assert.Equal(t, ssv1alpha1.StrictScope, sealedSecret.Scope())
+
+	output.Reset()
+	err = ks.seal(&output, strings.NewReader(mySecret), "my-namespace", ssv1alpha1.ClusterWideScope)
+	require.NoError(t, err)
+	err = runtime.DecodeInto(scheme.Codecs.UniversalDecoder(), output.Bytes(), &sealedSecret)
+	require.NoError(t, err)
+	assert.Equal(t, ssv1alpha1.ClusterWideScope, sealedSecret.Scope())
+}
+
+func TestKubeSeal_CertFile(t *testing.T) {
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(certFile, []byte(testCert), 0644))
+
+	ks := newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secret", certFile: certFile})
+	var output bytes.Buffer
+	const mySecret = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: my-secret
+type: Opaque
+stringData:
+  PASS: "1234"
+`
+	require.NoError(t, ks.seal(&output, strings.NewReader(mySecret), "my-namespace", ssv1alpha1.DefaultScope))
+	assert.NotNil(t, ks.publicKey)
+	assert.Contains(t, output.String(), "kind: SealedSecret")
+
+	ks = newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secret", certFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.Error(t, ks.seal(&output, strings.NewReader(mySecret), "my-namespace", ssv1alpha1.DefaultScope))
+}
+
+func TestKubeSeal_CertFingerprint(t *testing.T) {
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(certFile, []byte(testCert), 0644))
+	fingerprint, err := certFingerprint([]byte(testCert))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		wantErr     assert.ErrorAssertionFunc
+	}{
+		{"not pinned", "", assert.NoError},
+		{"match", fingerprint, assert.NoError},
+		{"upper case", strings.ToUpper(fingerprint), assert.NoError},
+		{"mismatch", strings.Repeat("0", 64), assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := newKubeSealer(kubeSealerConfig{certFile: certFile, certFingerprint: tt.fingerprint})
+			var output bytes.Buffer
+			tt.wantErr(t, ks.seal(&output, strings.NewReader(secretYAML("my-secret", "my-namespace")), "my-namespace", ssv1alpha1.DefaultScope))
+		})
+	}
+}
+
+func TestKubeSeal_MultipleDocuments(t *testing.T) {
+	ks := newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secret"})
+	var err error
+	ks.publicKey, err = kubeseal.ParseKey(strings.NewReader(testCert))
+	require.NoError(t, err)
+
+	const mySecrets = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: my-secret
+type: Opaque
+stringData:
+  PASS: "1234"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: my-other-secret
+type: Opaque
+stringData:
+  PASS: "5678"
+`
+	var output bytes.Buffer
+	require.NoError(t, ks.seal(&output, strings.NewReader(mySecrets), "my-namespace", ssv1alpha1.DefaultScope))
+
+	documents := strings.Split(strings.TrimPrefix(output.String(), "---\n"), "---\n")
+	require.Len(t, documents, 2)
+	for i, name := range []string{"my-secret", "my-other-secret"} {
+		var sealedSecret ssv1alpha1.SealedSecret
+		require.NoError(t, runtime.DecodeInto(scheme.Codecs.UniversalDecoder(), []byte(documents[i]), &sealedSecret))
+		assert.Equal(t, name, sealedSecret.GetName())
+		assert.Equal(t, "my-namespace", sealedSecret.GetNamespace())
+	}
+}
+
+func Test_initClient(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+  - name: staging
+    cluster:
+      server: https://staging.example.com
+  - name: production
+    cluster:
+      server: https://production.example.com
+contexts:
+  - name: staging
+    context:
+      cluster: staging
+  - name: production
+    context:
+      cluster: production
+current-context: staging
+`), 0600))
+
+	tests := []struct {
+		name    string
+		context string
+		want    string
+	}{
+		{"current context", "", "https://staging.example.com"},
+		{"selected context", "production", "https://production.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := initClient(kubeconfig, tt.context).ClientConfig()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.Host)
+		})
+	}
+}
+
+func TestKubeSeal_ExpectedContext(t *testing.T) {
+	tests := []struct {
+		name            string
+		expectedContext string
+		wantErr         assert.ErrorAssertionFunc
+	}{
+		{"no expected context", "", assert.NoError},
+		{"match", "test", assert.NoError},
+		{"mismatch", "production", assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets", expectedContext: tt.expectedContext})
+			ks.clientConfig = fakeController{cert: testCert, verify: func(*ssv1alpha1.SealedSecret) bool { return true }}.start(t)
+			_, err := ks.getPublicKey()
+			tt.wantErr(t, err)
+			tt.wantErr(t, ks.verify(strings.NewReader(`{"kind":"SealedSecret","apiVersion":"bitnami.com/v1alpha1","metadata":{"name":"test","namespace":"default"}}`)))
+		})
+	}
 }
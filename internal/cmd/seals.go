@@ -20,6 +20,9 @@ var (
 				level = slog.LevelDebug
 			}
 			charmer.SetLogger(cmd, slog.New(clilogger.NewHandler(os.Stdout, level)))
+			// several commands share flag names (e.g. output). Bind the flags of the command being run, so viper
+			// doesn't read the flag of whichever command was bound last.
+			_ = viper.BindPFlags(cmd.Flags())
 		},
 	}
 
@@ -39,10 +42,46 @@ func init() {
 	if err := charmer.SetPersistentFlags(RootCmd, viper.GetViper(), commonArgs); err != nil {
 		panic("failed to set command line flags: " + err.Error())
 	}
+	for _, cmd := range []*cobra.Command{sealCmd, reencryptCmd, verifyCmd, pinCmd} {
+		if err := charmer.SetPersistentFlags(cmd, viper.GetViper(), controllerArgs); err != nil {
+			panic("failed to set command line flags: " + err.Error())
+		}
+	}
+	if err := charmer.SetPersistentFlags(addCmd, viper.GetViper(), addArgs); err != nil {
+		panic("failed to set command line flags: " + err.Error())
+	}
+	for _, cmd := range []*cobra.Command{sealCmd, pinCmd} {
+		if err := charmer.SetPersistentFlags(cmd, viper.GetViper(), certArgs); err != nil {
+			panic("failed to set command line flags: " + err.Error())
+		}
+	}
 	if err := charmer.SetPersistentFlags(sealCmd, viper.GetViper(), sealArgs); err != nil {
 		panic("failed to set command line flags: " + err.Error())
 	}
+	if err := charmer.SetPersistentFlags(removeCmd, viper.GetViper(), removeArgs); err != nil {
+		panic("failed to set command line flags: " + err.Error())
+	}
+	if err := charmer.SetPersistentFlags(statusCmd, viper.GetViper(), statusArgs); err != nil {
+		panic("failed to set command line flags: " + err.Error())
+	}
+	if err := charmer.SetPersistentFlags(discoverCmd, viper.GetViper(), discoverArgs); err != nil {
+		panic("failed to set command line flags: " + err.Error())
+	}
+	if err := charmer.SetPersistentFlags(orphansCmd, viper.GetViper(), orphansArgs); err != nil {
+		panic("failed to set command line flags: " + err.Error())
+	}
+	if err := charmer.SetPersistentFlags(pinCmd, viper.GetViper(), pinArgs); err != nil {
+		panic("failed to set command line flags: " + err.Error())
+	}
+	if err := charmer.SetPersistentFlags(unsealCmd, viper.GetViper(), unsealArgs); err != nil {
+		panic("failed to set command line flags: " + err.Error())
+	}
+	for _, cmd := range []*cobra.Command{listCmd, statusCmd, verifyCmd, orphansCmd} {
+		if err := charmer.SetPersistentFlags(cmd, viper.GetViper(), outputArgs); err != nil {
+			panic("failed to set command line flags: " + err.Error())
+		}
+	}
 	viper.SetEnvPrefix("SEALS")
 	viper.AutomaticEnv()
-	RootCmd.AddCommand(listCmd, addCmd, sealCmd)
+	RootCmd.AddCommand(listCmd, addCmd, removeCmd, sealCmd, statusCmd, validateCmd, discoverCmd, orphansCmd, importCmd, reencryptCmd, verifyCmd, unsealCmd, pinCmd)
 }
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"errors"
+	"fmt"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"io/fs"
+	"os"
+)
+
+var (
+	statusArgs = charmer.Arguments{
+		"check": {Default: false, Help: "Exit with an error if any secret is not up to date"},
+	}
+
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show the sealing status of all secrets",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inventoryFile := viper.GetString("inventory")
+			inv, err := inventory.ReadFromFile(inventoryFile)
+			if err != nil {
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
+			}
+			lock, err := inventory.ReadLockFromFile(inventory.LockPath(inventoryFile))
+			if err != nil {
+				return fmt.Errorf("unable to load lock file: %w", err)
+			}
+			return status(os.Stdout, inv, lock, viper.GetViper())
+		},
+	}
+)
+
+// sealing state of a secret
+const (
+	stateUpToDate          = "up to date"
+	stateStale             = "stale"
+	stateNeverSealed       = "never sealed"
+	stateSourceMissing     = "source missing"
+	stateInvalidSource     = "invalid source"
+	stateNamespaceMismatch = "namespace mismatch"
+	stateInvalidScope      = "invalid scope"
+)
+
+func status(w io.Writer, inv inventory.Inventory, lock *inventory.Lock, v *viper.Viper) error {
+	reports, err := newSecretReports(inv, lock, v)
+	if err != nil {
+		return err
+	}
+	var notUpToDate int
+	for _, report := range reports {
+		if report.State != stateUpToDate {
+			notUpToDate++
+		}
+	}
+	if err := writeReports(w, v.GetString("output"), reports, true); err != nil {
+		return err
+	}
+	if v.GetBool("check") && notUpToDate > 0 {
+		return fmt.Errorf("%d secret(s) not up to date", notUpToDate)
+	}
+	return nil
+}
+
+// secretState determines the sealing state of a secret for the job's cluster.
+func secretState(inv inventory.Inventory, lock *inventory.Lock, job sealJob, v *viper.Viper) string {
+	secret := job.targetSecret()
+	source, destination := secretPaths(inv, secret, v)
+	namespace, err := getNamespaceFromSecret(source)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return stateSourceMissing
+	case err != nil:
+		return stateInvalidSource
+	case namespace != "" && namespace != secret.Namespace:
+		return stateNamespaceMismatch
+	}
+
+	reason, err := updateReason(source, destination, lock.Digest(job.lockKey()))
+	switch {
+	case err != nil:
+		return stateInvalidSource
+	case reason == destinationMissing:
+		return stateNeverSealed
+	case reason != upToDate:
+		return stateStale
+	default:
+		return stateUpToDate
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_status(t *testing.T) {
+	tmpdir := t.TempDir()
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+
+	const secret = `kind: Secret
+metadata:
+  namespace: default
+`
+	for _, source := range []string{"up-to-date", "stale", "never-sealed", "changed", "invalid-scope"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, source), []byte(secret), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "invalid"), []byte("kind: ConfigMap\n"), 0644))
+	for _, destination := range []string{"sealed-up-to-date", "sealed-stale", "sealed-changed"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, destination), []byte("sealed"), 0644))
+	}
+	require.NoError(t, os.Chtimes(filepath.Join(tmpdir, "up-to-date"), time.Now(), time.Now().Add(-time.Hour)))
+	require.NoError(t, os.Chtimes(filepath.Join(tmpdir, "sealed-stale"), time.Now(), time.Now().Add(-time.Hour)))
+	var lock inventory.Lock
+	lock.SetDigest("changed", "1234")
+
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: "."}
+	inv.Add(inventory.Secret{Source: "up-to-date", Destination: "sealed-up-to-date", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "stale", Destination: "sealed-stale", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "never-sealed", Destination: "sealed-never-sealed", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "changed", Destination: "sealed-changed", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "missing", Destination: "sealed-missing", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "invalid", Destination: "sealed-invalid", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "invalid-scope", Destination: "sealed-invalid-scope", Namespace: "default", Scope: "namespacewide"})
+
+	var out bytes.Buffer
+	require.NoError(t, status(&out, inv, &lock, v))
+	assert.Equal(t, `SOURCE         DESTINATION           NAMESPACE  SCOPE          STATE
+up-to-date     sealed-up-to-date     default    strict         up to date
+stale          sealed-stale          default    strict         stale
+never-sealed   sealed-never-sealed   default    strict         never sealed
+changed        sealed-changed        default    strict         stale
+missing        sealed-missing        default    strict         source missing
+invalid        sealed-invalid        default    strict         invalid source
+invalid-scope  sealed-invalid-scope  default    namespacewide  invalid scope
+`, out.String())
+
+	v.Set("check", true)
+	assert.Error(t, status(&out, inv, &lock, v))
+
+	// an invalid scope fails the check
+	inv = inventory.Inventory{SecretsDir: ".", DestinationDir: "."}
+	inv.Add(inventory.Secret{Source: "invalid-scope", Destination: "sealed-invalid-scope", Namespace: "default", Scope: "namespacewide"})
+	assert.EqualError(t, status(&out, inv, &lock, v), "1 secret(s) not up to date")
+
+	inv = inventory.Inventory{SecretsDir: ".", DestinationDir: "."}
+	inv.Add(inventory.Secret{Source: "up-to-date", Destination: "sealed-up-to-date", Namespace: "default"})
+	assert.NoError(t, status(&out, inv, &lock, v))
+
+	inv.Secrets[0].Namespace = "other"
+	assert.Equal(t, stateNamespaceMismatch, secretState(inv, &lock, sealJobs(inv.Secrets)[0], v))
+}
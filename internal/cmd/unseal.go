@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"codeberg.org/clambin/go-common/charmer"
+	"errors"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/kubeseal"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"io/fs"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+var (
+	unsealArgs = charmer.Arguments{
+		"key":   {Default: "", Help: "Private key of the sealed-secrets controller (required)"},
+		"force": {Default: false, Help: "Overwrite existing secrets"},
+	}
+
+	unsealCmd = &cobra.Command{
+		Use:   "unseal [flags] [secret...]",
+		Short: "Recover secrets from their sealed secrets, using the controller's private key",
+		Long: `Unseal decrypts sealed secrets back into secrets, using a backup of the sealed-secrets controller's private key.
+If no secrets are specified, all secrets in the inventory are recovered.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := viper.GetString("key")
+			if key == "" {
+				return errors.New("no private key specified")
+			}
+			inv, err := inventory.ReadFromFile(viper.GetString("inventory"))
+			if err != nil {
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
+			}
+			return unseal(keyUnsealer{keyFiles: []string{key}}, inv, args, viper.GetViper(), charmer.GetLogger(cmd))
+		},
+	}
+)
+
+// unsealer interface so we can stub during unit testing
+type unsealer interface {
+	unseal(w io.Writer, r io.Reader) error
+}
+
+// unseal recovers the secrets from their sealed secrets. If sources is empty, all secrets in the inventory are recovered.
+func unseal(u unsealer, inv inventory.Inventory, sources []string, v *viper.Viper, l *slog.Logger) error {
+	secrets, err := selectSecrets(inv, sources, v)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, secret := range secrets {
+		if err = unsealSecret(u, inv, secret, v); err != nil {
+			errs = append(errs, fmt.Errorf("failed to unseal %q: %w", secret.Source, err))
+			continue
+		}
+		l.Info("secret recovered", "secret", secret.Source)
+	}
+	return errors.Join(errs...)
+}
+
+// selectSecrets returns the secrets in the inventory for the sources. If sources is empty, it returns all secrets.
+// Sources are resolved in the same way as the add command.
+func selectSecrets(inv inventory.Inventory, sources []string, v *viper.Viper) ([]inventory.Secret, error) {
+	if len(sources) == 0 {
+		return inv.Secrets, nil
+	}
+	secrets := make([]inventory.Secret, 0, len(sources))
+	for _, source := range sources {
+		relSource, err := makeRelativePath(filepath.Join(v.GetString("ansible"), inv.SecretsDir), source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make relative path: %w", err)
+		}
+		var found bool
+		for _, secret := range inv.Secrets {
+			if secret.Source == relSource {
+				secrets = append(secrets, secret)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("secret %q not found in inventory", relSource)
+		}
+	}
+	return secrets, nil
+}
+
+// unsealSecret recovers the secret from its sealed secret. If the secret is sealed for several clusters, the sealed
+// secret of each cluster is tried in turn, until one can be unsealed with the private key.
+func unsealSecret(u unsealer, inv inventory.Inventory, secret inventory.Secret, v *viper.Viper) error {
+	secretFile, _ := secretPaths(inv, secret, v)
+	if _, err := os.Stat(secretFile); !errors.Is(err, fs.ErrNotExist) && !v.GetBool("force") {
+		return fmt.Errorf("%s already exists", secretFile)
+	}
+	var errs []error
+	for _, job := range sealJobs([]inventory.Secret{secret}) {
+		_, sealedSecretFile := secretPaths(inv, job.targetSecret(), v)
+		err := unsealFile(u, sealedSecretFile, secretFile)
+		if err == nil {
+			return nil
+		}
+		if job.target.Cluster != "" {
+			err = fmt.Errorf("%s: %w", job.target.Cluster, err)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func unsealFile(u unsealer, sealedSecretFile, secretFile string) error {
+	f, err := os.Open(sealedSecretFile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	// secrets hold plaintext data: don't make them readable to others
+	return writeFileAtomic(secretFile, 0600, func(w io.Writer) error {
+		return u.unseal(w, f)
+	})
+}
+
+// keyUnsealer unseals sealed secrets with the controller's private key(s).
+type keyUnsealer struct {
+	keyFiles []string
+}
+
+// unseal decrypts each SealedSecret in r and writes the resulting Secrets to w.
+func (k keyUnsealer) unseal(w io.Writer, r io.Reader) error {
+	reader := yaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+		if err = kubeseal.UnsealSealedSecret(w, bytes.NewReader(document), k.keyFiles, "yaml", scheme.Codecs); err != nil {
+			return err
+		}
+	}
+}
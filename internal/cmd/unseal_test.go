@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	ssv1alpha1 "github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_unseal(t *testing.T) {
+	tmpdir := t.TempDir()
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+
+	// seal a secret with a local keypair
+	cert, key := newTestKeyPair(t)
+	certFile := filepath.Join(tmpdir, "cert.pem")
+	keyFile := filepath.Join(tmpdir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, cert, 0644))
+	require.NoError(t, os.WriteFile(keyFile, key, 0600))
+
+	const secrets = `apiVersion: v1
+kind: Secret
+metadata:
+  name: foo
+  namespace: default
+stringData:
+  PASS: "1234"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: bar
+  namespace: default
+stringData:
+  PASS: "5678"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "secret"), []byte(secrets), 0644))
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: "."}
+	inv.Add(inventory.Secret{Source: "secret", Destination: "sealed-secret", Namespace: "default"})
+	require.NoError(t, seal(sealers{"": newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets", certFile: certFile})}, inv, &inventory.Lock{}, v, slog.Default()))
+
+	// secret exists
+	u := keyUnsealer{keyFiles: []string{keyFile}}
+	assert.Error(t, unseal(u, inv, nil, v, slog.Default()))
+
+	// secret is lost
+	require.NoError(t, os.Remove(filepath.Join(tmpdir, "secret")))
+	require.NoError(t, unseal(u, inv, []string{filepath.Join(tmpdir, "secret")}, v, slog.Default()))
+
+	f, err := os.Open(filepath.Join(tmpdir, "secret"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	dec := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for _, want := range []struct{ name, pass string }{{"foo", "1234"}, {"bar", "5678"}} {
+		var secret v1.Secret
+		require.NoError(t, dec.Decode(&secret))
+		assert.Equal(t, want.name, secret.GetName())
+		assert.Equal(t, want.pass, string(secret.Data["PASS"]))
+	}
+	fInfo, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fInfo.Mode().Perm())
+
+	// force overwrites the secret
+	v.Set("force", true)
+	assert.NoError(t, unseal(u, inv, nil, v, slog.Default()))
+
+	// unknown secret
+	assert.Error(t, unseal(u, inv, []string{filepath.Join(tmpdir, "missing")}, v, slog.Default()))
+
+	// wrong key
+	_, otherKey := newTestKeyPair(t)
+	require.NoError(t, os.WriteFile(keyFile, otherKey, 0600))
+	err = unseal(u, inv, nil, v, slog.Default())
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), `failed to unseal "secret"`))
+}
+
+func Test_unseal_clusters(t *testing.T) {
+	tmpdir := t.TempDir()
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+
+	// each cluster has its own keypair
+	keys := make(map[string]string)
+	s := make(sealers)
+	for _, cluster := range []string{"staging", "production"} {
+		cert, key := newTestKeyPair(t)
+		certFile := filepath.Join(tmpdir, cluster+"-cert.pem")
+		keys[cluster] = filepath.Join(tmpdir, cluster+"-key.pem")
+		require.NoError(t, os.WriteFile(certFile, cert, 0644))
+		require.NoError(t, os.WriteFile(keys[cluster], key, 0600))
+		s[cluster] = newKubeSealer(kubeSealerConfig{certFile: certFile})
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "secret"), []byte(secretYAML("foo", "default")+"stringData:\n  PASS: \"1234\"\n"), 0644))
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: ".", Clusters: []inventory.Cluster{{Name: "staging"}, {Name: "production"}}}
+	inv.Add(inventory.Secret{Source: "secret", Namespace: "default", Clusters: []inventory.Target{
+		{Cluster: "staging", Destination: "staging-secret"},
+		{Cluster: "production", Destination: "production-secret"},
+	}})
+	require.NoError(t, seal(s, inv, &inventory.Lock{}, v, slog.Default()))
+	require.NoError(t, os.Remove(filepath.Join(tmpdir, "secret")))
+
+	// the production key only decrypts the production sealed secret
+	require.NoError(t, unseal(keyUnsealer{keyFiles: []string{keys["production"]}}, inv, nil, v, slog.Default()))
+	assert.FileExists(t, filepath.Join(tmpdir, "secret"))
+
+	// neither sealed secret can be decrypted
+	v.Set("force", true)
+	_, otherKey := newTestKeyPair(t)
+	require.NoError(t, os.WriteFile(keys["production"], otherKey, 0600))
+	err := unseal(keyUnsealer{keyFiles: []string{keys["production"]}}, inv, nil, v, slog.Default())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "staging: ")
+	assert.Contains(t, err.Error(), "production: ")
+}
+
+// newTestKeyPair returns a PEM-encoded, self-signed certificate and its private key, as used by the sealed-secrets controller.
+func newTestKeyPair(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: ssv1alpha1.SchemeGroupVersion.Group},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}),
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
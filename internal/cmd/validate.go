@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"errors"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+)
+
+var (
+	validateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the inventory",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inventoryFile := viper.GetString("inventory")
+			inv, err := inventory.ReadFromFile(inventoryFile)
+			if err != nil {
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
+			}
+			if err = errors.Join(checkUnknownFields(inventoryFile), validate(inv, viper.GetViper())); err != nil {
+				return fmt.Errorf("inventory is invalid:\n%w", err)
+			}
+			charmer.GetLogger(cmd).Info("inventory is valid")
+			return nil
+		},
+	}
+)
+
+// checkUnknownFields reports any fields in the inventory file that don't map onto the inventory.
+func checkUnknownFields(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	var inv inventory.Inventory
+	if err = dec.Decode(&inv); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks each secret in the inventory and returns all problems found.
+func validate(inv inventory.Inventory, v *viper.Viper) error {
+	var errs []error
+	if err := validateScope(inv.Scope); err != nil {
+		errs = append(errs, fmt.Errorf("inventory: %w", err))
+	}
+	errs = append(errs, validateClusters(inv)...)
+	destinations := make(map[string]string)
+	for _, secret := range inv.Secrets {
+		source, _ := secretPaths(inv, secret, v)
+		if err := validateSource(source, secret.Namespace); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", secret.Source, err))
+		}
+		if err := validateScope(secret.Scope); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", secret.Source, err))
+		}
+		if secret.Destination != "" && len(secret.Clusters) > 0 {
+			errs = append(errs, fmt.Errorf("%s: destination must be set per cluster", secret.Source))
+		}
+		for _, target := range secret.Targets() {
+			if _, ok := inv.Cluster(target.Cluster); target.Cluster != "" && !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown cluster %q", secret.Source, target.Cluster))
+			}
+			_, destination := secretPaths(inv, sealJob{secret: secret, target: target}.targetSecret(), v)
+			if other, ok := destinations[destination]; ok {
+				errs = append(errs, fmt.Errorf("%s: destination %q is also used by %s", secret.Source, target.Destination, other))
+			}
+			destinations[destination] = secret.Source
+			if err := isWritableDirectory(filepath.Dir(destination)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid destination directory: %w", secret.Source, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateClusters checks that each cluster in the inventory has a unique name.
+func validateClusters(inv inventory.Inventory) []error {
+	var errs []error
+	names := make(map[string]struct{}, len(inv.Clusters))
+	for _, cluster := range inv.Clusters {
+		if cluster.Name == "" {
+			errs = append(errs, errors.New("cluster has no name"))
+			continue
+		}
+		if _, ok := names[cluster.Name]; ok {
+			errs = append(errs, fmt.Errorf("cluster %q is defined more than once", cluster.Name))
+		}
+		names[cluster.Name] = struct{}{}
+	}
+	return errs
+}
+
+func validateSource(source, namespace string) error {
+	namespaceFromSecret, err := getNamespaceFromSecret(source)
+	if err != nil {
+		return err
+	}
+	if namespaceFromSecret != "" && namespaceFromSecret != namespace {
+		return fmt.Errorf("namespace mismatch: secret has %q, inventory has %q", namespaceFromSecret, namespace)
+	}
+	return nil
+}
+
+func validateScope(scope string) error {
+	var sealingScope v1alpha1.SealingScope
+	if err := sealingScope.Set(scope); err != nil {
+		return fmt.Errorf("invalid scope %q: %w", scope, err)
+	}
+	return nil
+}
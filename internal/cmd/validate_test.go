@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_validate(t *testing.T) {
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+
+	v := viper.New()
+	v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "secrets", "valid.yaml"), []byte(`kind: Secret
+metadata:
+  namespace: default
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "secrets", "configmap.yaml"), []byte(`kind: ConfigMap
+`), 0644))
+
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+	inv.Add(inventory.Secret{Source: "valid.yaml", Destination: "valid.yaml", Namespace: "default"})
+	require.NoError(t, validate(inv, v))
+
+	inv.Add(inventory.Secret{Source: "missing.yaml", Destination: "missing.yaml", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "configmap.yaml", Destination: "configmap.yaml", Namespace: "default"})
+	inv.Secrets = append(inv.Secrets, inventory.Secret{Source: "valid.yaml", Destination: "valid.yaml", Namespace: "not-default"})
+	inv.Secrets = append(inv.Secrets, inventory.Secret{Source: "valid.yaml", Destination: "missing/valid.yaml", Namespace: "default", Scope: "invalid"})
+
+	err := validate(inv, v)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing.yaml: open ")
+	assert.Contains(t, err.Error(), `configmap.yaml: secret kind in`)
+	assert.Contains(t, err.Error(), `valid.yaml: namespace mismatch: secret has "default", inventory has "not-default"`)
+	assert.Contains(t, err.Error(), `valid.yaml: destination "valid.yaml" is also used by valid.yaml`)
+	assert.Contains(t, err.Error(), `valid.yaml: invalid scope "invalid"`)
+	assert.Contains(t, err.Error(), `valid.yaml: invalid destination directory: stat:`)
+}
+
+func Test_validate_clusters(t *testing.T) {
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+
+	v := viper.New()
+	v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "secrets", "valid.yaml"), []byte(`kind: Secret
+metadata:
+  namespace: default
+`), 0644))
+
+	inv := inventory.Inventory{
+		SecretsDir:     "../secrets",
+		DestinationDir: "../manifests",
+		Clusters:       []inventory.Cluster{{Name: "staging"}, {Name: "production"}},
+	}
+	inv.Add(inventory.Secret{Source: "valid.yaml", Namespace: "default", Clusters: []inventory.Target{
+		{Cluster: "staging", Destination: "staging-valid.yaml"},
+		{Cluster: "production", Destination: "production-valid.yaml"},
+	}})
+	require.NoError(t, validate(inv, v))
+
+	inv.Clusters = append(inv.Clusters, inventory.Cluster{Name: "staging"}, inventory.Cluster{})
+	inv.Secrets[0].Destination = "valid.yaml"
+	inv.Secrets[0].Clusters = append(inv.Secrets[0].Clusters,
+		inventory.Target{Cluster: "dev", Destination: "dev-valid.yaml"},
+		inventory.Target{Cluster: "production", Destination: "staging-valid.yaml"},
+	)
+
+	err := validate(inv, v)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `cluster "staging" is defined more than once`)
+	assert.Contains(t, err.Error(), `cluster has no name`)
+	assert.Contains(t, err.Error(), `valid.yaml: destination must be set per cluster`)
+	assert.Contains(t, err.Error(), `valid.yaml: unknown cluster "dev"`)
+	assert.Contains(t, err.Error(), `valid.yaml: destination "staging-valid.yaml" is also used by valid.yaml`)
+}
+
+func Test_checkUnknownFields(t *testing.T) {
+	tmpdir := t.TempDir()
+	inventoryFile := filepath.Join(tmpdir, "inventory.yaml")
+
+	require.NoError(t, os.WriteFile(inventoryFile, []byte(`secrets_dir: secrets
+destination_dir: manifests
+secrets:
+  - source: secret.yaml
+    destination: sealed-secret.yaml
+    namespace: default
+`), 0644))
+	assert.NoError(t, checkUnknownFields(inventoryFile))
+
+	require.NoError(t, os.WriteFile(inventoryFile, []byte(`secrets_dir: secrets
+destination_dir: manifests
+secrets:
+  - source: secret.yaml
+    destinaton: sealed-secret.yaml
+    namespace: default
+`), 0644))
+	err := checkUnknownFields(inventoryFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "field destinaton not found")
+}
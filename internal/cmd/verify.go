@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"fmt"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"log/slog"
+	"os"
+)
+
+var (
+	verifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Verify that the controller can decrypt all sealed secrets",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inv, err := inventory.ReadFromFile(viper.GetString("inventory"))
+			if err != nil {
+				return fmt.Errorf("unable to load ansible inventory file: %w", err)
+			}
+			kubeSealers, err := newKubeSealers(inv, nil, viper.GetViper())
+			if err != nil {
+				return err
+			}
+			s := make(verifiers, len(kubeSealers))
+			for name, ks := range kubeSealers {
+				s[name] = ks
+			}
+			return verify(os.Stdout, s, inv, viper.GetViper(), charmer.GetLogger(cmd))
+		},
+	}
+)
+
+// verifier interface so we can stub during unit testing
+type verifier interface {
+	verify(r io.Reader) error
+}
+
+// verifiers holds the verifier for each cluster in the inventory. The verifier for the default cluster has no name.
+type verifiers map[string]verifier
+
+// verification state of a sealed secret
+const (
+	stateVerified          = "verified"
+	stateCannotBeDecrypted = "cannot be decrypted"
+)
+
+// verify checks that each cluster's controller can decrypt the sealed secrets of every secret in the inventory.
+// In table format, the result of each check is logged. Otherwise, the results are written to w as reports.
+func verify(w io.Writer, s verifiers, inv inventory.Inventory, v *viper.Viper, l *slog.Logger) error {
+	format := v.GetString("output")
+	var failed int
+	var reports []secretReport
+	for _, job := range sealJobs(inv.Secrets) {
+		report, err := newSecretReport(inv, job, v)
+		if err != nil {
+			return err
+		}
+		logger := job.logger(l)
+		err = fmt.Errorf("unknown cluster %q", job.target.Cluster)
+		if clusterVerifier, ok := s[job.target.Cluster]; ok {
+			err = verifyFile(clusterVerifier, report.DestinationPath)
+		}
+		report.State = stateVerified
+		if err != nil {
+			report.State = stateCannotBeDecrypted
+			failed++
+		}
+		reports = append(reports, report)
+		if !isTableOutput(format) {
+			continue
+		}
+		if err != nil {
+			logger.Error("sealed secret cannot be decrypted", "secret", report.Destination, "err", err)
+		} else {
+			logger.Info("sealed secret verified", "secret", report.Destination)
+		}
+	}
+	if !isTableOutput(format) {
+		if err := writeReports(w, format, reports, true); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d sealed secret(s) cannot be decrypted", failed)
+	}
+	return nil
+}
+
+func verifyFile(s verifier, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return s.verify(f)
+}
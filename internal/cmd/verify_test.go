@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	ssv1alpha1 "github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/clambin/seals/internal/clilogger"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_verify(t *testing.T) {
+	tmpdir := t.TempDir()
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+
+	const sealedSecret = `apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: %s
+  namespace: default
+spec:
+  encryptedData:
+    PASS: secret
+`
+	for _, name := range []string{"valid", "retired"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "sealed-"+name), []byte(fmt.Sprintf(sealedSecret, name)), 0644))
+	}
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: "."}
+	inv.Add(inventory.Secret{Source: "valid", Destination: "sealed-valid", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "retired", Destination: "sealed-retired", Namespace: "default"})
+	inv.Add(inventory.Secret{Source: "missing", Destination: "sealed-missing", Namespace: "default"})
+
+	ks := newKubeSealer(kubeSealerConfig{controllerNamespace: "sealed-secrets", controllerName: "sealed-secrets"})
+	ks.clientConfig = fakeController{
+		verify: func(ss *ssv1alpha1.SealedSecret) bool { return ss.GetName() == "valid" },
+	}.start(t)
+
+	var out bytes.Buffer
+	err := verify(io.Discard, verifiers{"": ks}, inv, v, slog.New(clilogger.NewHandler(&out, slog.LevelInfo)))
+	assert.EqualError(t, err, "2 sealed secret(s) cannot be decrypted")
+	assert.Contains(t, out.String(), "INFO sealed secret verified (secret=sealed-valid)\n")
+	assert.Contains(t, out.String(), "ERROR sealed secret cannot be decrypted (secret=sealed-retired, err=unable to decrypt sealed secret: retired)\n")
+	assert.Contains(t, out.String(), "ERROR sealed secret cannot be decrypted (secret=sealed-missing, err=open ")
+
+	// in json format, the results are reported instead of logged
+	out.Reset()
+	v.Set("output", "json")
+	var reports bytes.Buffer
+	err = verify(&reports, verifiers{"": ks}, inv, v, slog.New(clilogger.NewHandler(&out, slog.LevelInfo)))
+	assert.EqualError(t, err, "2 sealed secret(s) cannot be decrypted")
+	assert.Empty(t, out.String())
+	var got []secretReport
+	require.NoError(t, json.Unmarshal(reports.Bytes(), &got))
+	require.Len(t, got, 3)
+	for i, want := range []string{stateVerified, stateCannotBeDecrypted, stateCannotBeDecrypted} {
+		assert.Equal(t, want, got[i].State, got[i].Source)
+	}
+}
+
+func Test_verify_clusters(t *testing.T) {
+	tmpdir := t.TempDir()
+	v := viper.New()
+	v.Set("ansible", tmpdir)
+
+	for _, cluster := range []string{"staging", "production"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, cluster+"-sealed"), []byte(cluster), 0644))
+	}
+	inv := inventory.Inventory{SecretsDir: ".", DestinationDir: ".", Clusters: []inventory.Cluster{{Name: "staging"}, {Name: "production"}}}
+	inv.Add(inventory.Secret{Source: "secret", Namespace: "default", Clusters: []inventory.Target{
+		{Cluster: "staging", Destination: "staging-sealed"},
+		{Cluster: "production", Destination: "production-sealed"},
+	}})
+
+	// each cluster's sealed secret is verified by that cluster's controller
+	var out bytes.Buffer
+	s := verifiers{"staging": clusterVerifier("staging"), "production": clusterVerifier("production")}
+	require.NoError(t, verify(io.Discard, s, inv, v, slog.New(clilogger.NewHandler(&out, slog.LevelInfo))))
+	assert.Contains(t, out.String(), "INFO sealed secret verified (cluster=staging, secret=staging-sealed)\n")
+	assert.Contains(t, out.String(), "INFO sealed secret verified (cluster=production, secret=production-sealed)\n")
+
+	// unknown cluster
+	out.Reset()
+	delete(s, "production")
+	assert.Error(t, verify(io.Discard, s, inv, v, slog.New(clilogger.NewHandler(&out, slog.LevelInfo))))
+	assert.Contains(t, out.String(), `ERROR sealed secret cannot be decrypted (cluster=production, secret=production-sealed, err=unknown cluster "production")`)
+}
+
+var _ verifier = clusterVerifier("")
+
+// clusterVerifier only verifies sealed secrets that hold the name of its cluster.
+type clusterVerifier string
+
+func (c clusterVerifier) verify(r io.Reader) error {
+	body, err := io.ReadAll(r)
+	if err == nil && string(body) != string(c) {
+		err = fmt.Errorf("sealed secret is not for %s", c)
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// watchDebounce is how long the watcher waits for further changes before sealing the changed secrets.
+var watchDebounce = 500 * time.Millisecond
+
+// watcher reseals secrets when their source changes. If the inventory changes, it is reloaded.
+type watcher struct {
+	sealers       sealers
+	makeSealers   func(inventory.Inventory) (sealers, error)
+	inventoryFile string
+	inv           inventory.Inventory
+	lock          *inventory.Lock
+	v             *viper.Viper
+	l             *slog.Logger
+	fsWatcher     *fsnotify.Watcher
+	watched       map[string]struct{}
+}
+
+// watch reseals secrets when they change. makeSealers creates the sealers for the inventory when it is reloaded.
+func watch(ctx context.Context, s sealers, makeSealers func(inventory.Inventory) (sealers, error), inventoryFile string, inv inventory.Inventory, lock *inventory.Lock, v *viper.Viper, l *slog.Logger) error {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to create watcher: %w", err)
+	}
+	defer func() { _ = fsWatcher.Close() }()
+
+	w := watcher{
+		sealers:       s,
+		makeSealers:   makeSealers,
+		inventoryFile: filepath.Clean(inventoryFile),
+		inv:           inv,
+		lock:          lock,
+		v:             v,
+		l:             l,
+		fsWatcher:     fsWatcher,
+		watched:       make(map[string]struct{}),
+	}
+	return w.run(ctx)
+}
+
+func (w *watcher) run(ctx context.Context) error {
+	if err := w.addWatches(); err != nil {
+		return err
+	}
+	w.l.Info("watching for changes")
+
+	pending := make(map[string]struct{})
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
+				pending[filepath.Clean(event.Name)] = struct{}{}
+				timer.Reset(watchDebounce)
+			}
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return nil
+			}
+			w.l.Error("watcher failed", "err", err)
+		case <-timer.C:
+			w.handleChanges(pending)
+			clear(pending)
+		}
+	}
+}
+
+// handleChanges seals the secrets whose source is in changed. If the inventory changed, it is reloaded and all secrets
+// are checked.
+func (w *watcher) handleChanges(changed map[string]struct{}) {
+	var secrets []inventory.Secret
+	if _, ok := changed[w.inventoryFile]; ok {
+		if err := w.reloadInventory(); err != nil {
+			w.l.Error("failed to reload inventory", "err", err)
+			return
+		}
+		secrets = w.inv.Secrets
+	} else {
+		for _, secret := range w.inv.Secrets {
+			source, _ := secretPaths(w.inv, secret, w.v)
+			if _, ok := changed[source]; ok {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	if len(secrets) == 0 {
+		return
+	}
+
+	for _, job := range sealJobs(secrets) {
+		if _, err := maybeSeal(w.sealers, w.inv, w.lock, job, w.v, w.l.With("secret", job.name())); err != nil {
+			w.l.Error("failed to seal secret", "secret", job.name(), "err", err)
+		}
+	}
+	if err := w.lock.WriteToFile(inventory.LockPath(w.inventoryFile)); err != nil {
+		w.l.Error("failed to write lock file", "err", err)
+	}
+}
+
+func (w *watcher) reloadInventory() error {
+	inv, err := inventory.ReadFromFile(w.inventoryFile)
+	if err != nil {
+		return err
+	}
+	w.l.Info("inventory reloaded")
+	w.inv = inv
+	if s, err := w.makeSealers(inv); err != nil {
+		w.l.Error("failed to update sealers, keeping current sealers", "err", err)
+	} else {
+		w.sealers = s
+	}
+	return w.addWatches()
+}
+
+// addWatches watches the directories holding the inventory and all secrets. Directories are watched, rather than
+// files, so we also see files that editors replace rather than update.
+func (w *watcher) addWatches() error {
+	dirs := []string{filepath.Dir(w.inventoryFile)}
+	for _, secret := range w.inv.Secrets {
+		source, _ := secretPaths(w.inv, secret, w.v)
+		dirs = append(dirs, filepath.Dir(source))
+	}
+	for _, dir := range dirs {
+		if _, ok := w.watched[dir]; ok {
+			continue
+		}
+		if err := w.fsWatcher.Add(dir); err != nil {
+			return fmt.Errorf("unable to watch %q: %w", dir, err)
+		}
+		w.watched[dir] = struct{}{}
+	}
+	return nil
+}
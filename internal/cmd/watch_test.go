@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_watch(t *testing.T) {
+	debounce := watchDebounce
+	watchDebounce = 10 * time.Millisecond
+	t.Cleanup(func() { watchDebounce = debounce })
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+
+	v := viper.New()
+	v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+
+	inventoryFile := filepath.Join(tmpdir, "ansible", "inventory.yaml")
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+	inv.Add(inventory.Secret{Source: "foo.yaml", Destination: "sealed-foo.yaml", Namespace: "default"})
+	require.NoError(t, inv.WriteToFile(inventoryFile))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "secrets", "foo.yaml"), []byte(secretYAML("foo", "default")), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "secrets", "bar.yaml"), []byte(secretYAML("bar", "default")), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- watch(ctx, sealers{"": fakeSealer{}}, fakeSealers, inventoryFile, inv, &inventory.Lock{}, v, slog.Default())
+	}()
+
+	// updating a secret seals it. keep updating it until the watcher has started and picks up the change.
+	assert.Eventually(t, func() bool {
+		if err := os.WriteFile(filepath.Join(tmpdir, "secrets", "foo.yaml"), []byte(secretYAML("foo-2", "default")), 0644); err != nil {
+			return false
+		}
+		content, err := os.ReadFile(filepath.Join(tmpdir, "manifests", "sealed-foo.yaml"))
+		return err == nil && string(content) == secretYAML("foo-2", "default")
+	}, time.Second, 10*time.Millisecond)
+	assert.FileExists(t, inventory.LockPath(inventoryFile))
+
+	// updating the inventory reloads it. the watcher owns inv, so write a separate inventory.
+	updated := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+	updated.Add(inventory.Secret{Source: "foo.yaml", Destination: "sealed-foo.yaml", Namespace: "default"})
+	updated.Add(inventory.Secret{Source: "bar.yaml", Destination: "sealed-bar.yaml", Namespace: "default"})
+	require.NoError(t, updated.WriteToFile(inventoryFile))
+	assert.Eventually(t, func() bool {
+		content, err := os.ReadFile(filepath.Join(tmpdir, "manifests", "sealed-bar.yaml"))
+		return err == nil && string(content) == secretYAML("bar", "default")
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func Test_watch_clusters(t *testing.T) {
+	debounce := watchDebounce
+	watchDebounce = 10 * time.Millisecond
+	t.Cleanup(func() { watchDebounce = debounce })
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+
+	v := viper.New()
+	v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+
+	inventoryFile := filepath.Join(tmpdir, "ansible", "inventory.yaml")
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests", Clusters: []inventory.Cluster{{Name: "staging"}}}
+	inv.Add(inventory.Secret{Source: "foo.yaml", Namespace: "default", Clusters: []inventory.Target{{Cluster: "staging", Destination: "staging-foo.yaml"}}})
+	require.NoError(t, inv.WriteToFile(inventoryFile))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "secrets", "foo.yaml"), []byte(secretYAML("foo", "default")), 0644))
+
+	s, err := fakeSealers(inv)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- watch(ctx, s, fakeSealers, inventoryFile, inv, &inventory.Lock{}, v, slog.Default())
+	}()
+
+	// adding a cluster to the inventory creates its sealer. keep updating the inventory until the watcher has started.
+	// the watcher owns inv, so write a separate inventory.
+	updated := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests", Clusters: []inventory.Cluster{{Name: "staging"}, {Name: "production"}}}
+	updated.Add(inventory.Secret{Source: "foo.yaml", Namespace: "default", Clusters: []inventory.Target{
+		{Cluster: "staging", Destination: "staging-foo.yaml"},
+		{Cluster: "production", Destination: "production-foo.yaml"},
+	}})
+	assert.Eventually(t, func() bool {
+		if err := updated.WriteToFile(inventoryFile); err != nil {
+			return false
+		}
+		content, err := os.ReadFile(filepath.Join(tmpdir, "manifests", "production-foo.yaml"))
+		return err == nil && string(content) == "production"
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+// fakeSealers returns a clusterSealer for each cluster in the inventory.
+func fakeSealers(inv inventory.Inventory) (sealers, error) {
+	s := sealers{"": fakeSealer{}}
+	for _, cluster := range inv.Clusters {
+		s[cluster.Name] = clusterSealer(cluster.Name)
+	}
+	return s, nil
+}
+
+func Test_watcher_reloadInventory(t *testing.T) {
+	tmpdir := t.TempDir()
+	require.NoError(t, initFS(tmpdir))
+	v := viper.New()
+	v.Set("ansible", filepath.Join(tmpdir, "ansible"))
+	inventoryFile := filepath.Join(tmpdir, "ansible", "inventory.yaml")
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests", Clusters: []inventory.Cluster{{Name: "staging"}}}
+	require.NoError(t, inv.WriteToFile(inventoryFile))
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = fsWatcher.Close() })
+	current := sealers{"": fakeSealer{}}
+	w := watcher{
+		sealers:       current,
+		makeSealers:   func(inventory.Inventory) (sealers, error) { return nil, errors.New("invalid clusters") },
+		inventoryFile: inventoryFile,
+		v:             v,
+		l:             slog.Default(),
+		fsWatcher:     fsWatcher,
+		watched:       make(map[string]struct{}),
+	}
+
+	// sealers can't be created: keep the current ones
+	require.NoError(t, w.reloadInventory())
+	assert.Equal(t, current, w.sealers)
+	assert.Len(t, w.inv.Clusters, 1)
+
+	// sealers are rebuilt
+	w.makeSealers = fakeSealers
+	require.NoError(t, w.reloadInventory())
+	assert.Equal(t, sealers{"": fakeSealer{}, "staging": clusterSealer("staging")}, w.sealers)
+}
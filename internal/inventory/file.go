@@ -0,0 +1,41 @@
+package inventory
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFile writes a file by writing it to a temporary file and renaming it, so readers never see a partially written
+// file. An existing file keeps its permissions.
+func writeFile(filename string, write func(w io.Writer) error) (err error) {
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(filename); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return fmt.Errorf("chmod: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	if err = os.Rename(f.Name(), filename); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+	return nil
+}
@@ -7,22 +7,65 @@ import (
 )
 
 type Inventory struct {
-	SecretsDir     string   `yaml:"secrets_dir"`
-	DestinationDir string   `yaml:"destination_dir"`
-	Secrets        []Secret `yaml:"secrets"`
+	// node holds the document the inventory was read from, so Write can preserve its comments, ordering and quoting.
+	node                *yaml.Node
+	SecretsDir          string    `yaml:"secrets_dir"`
+	DestinationDir      string    `yaml:"destination_dir"`
+	Cert                string    `yaml:"cert,omitempty"`
+	CertFingerprint     string    `yaml:"cert_fingerprint,omitempty"`
+	Context             string    `yaml:"context,omitempty"`
+	ControllerName      string    `yaml:"controller_name,omitempty"`
+	ControllerNamespace string    `yaml:"controller_namespace,omitempty"`
+	Scope               string    `yaml:"scope,omitempty"`
+	Clusters            []Cluster `yaml:"clusters,omitempty"`
+	Secrets             []Secret  `yaml:"secrets"`
+}
+
+// Cluster is a cluster that secrets can be sealed for, with its own sealed-secrets controller.
+// Any field that isn't set falls back to the default for the inventory.
+type Cluster struct {
+	Name                string `yaml:"name"`
+	Context             string `yaml:"context,omitempty"`
+	ControllerName      string `yaml:"controller_name,omitempty"`
+	ControllerNamespace string `yaml:"controller_namespace,omitempty"`
+	Cert                string `yaml:"cert,omitempty"`
+	CertFingerprint     string `yaml:"cert_fingerprint,omitempty"`
 }
 
 type Secret struct {
-	Source      string `yaml:"source"`
+	Source      string   `yaml:"source"`
+	Destination string   `yaml:"destination,omitempty"`
+	Namespace   string   `yaml:"namespace"`
+	Scope       string   `yaml:"scope,omitempty"`
+	Clusters    []Target `yaml:"clusters,omitempty"`
+}
+
+// Target is a cluster that a secret is sealed for, and the destination of its sealed secret for that cluster.
+// The default cluster has no name.
+type Target struct {
+	Cluster     string `yaml:"cluster"`
 	Destination string `yaml:"destination"`
-	Namespace   string `yaml:"namespace"`
+}
+
+// Targets returns the clusters the secret is sealed for. A secret that doesn't list any clusters is sealed for the
+// default cluster, in its Destination.
+func (s Secret) Targets() []Target {
+	if len(s.Clusters) == 0 {
+		return []Target{{Destination: s.Destination}}
+	}
+	return s.Clusters
 }
 
 func Read(r io.Reader) (Inventory, error) {
 	var inv Inventory
-	if err := yaml.NewDecoder(r).Decode(&inv); err != nil {
+	var node yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&node); err != nil {
 		return inv, err
 	}
+	if err := node.Decode(&inv); err != nil {
+		return inv, err
+	}
+	inv.node = &node
 	return inv, nil
 }
 
@@ -35,24 +78,54 @@ func ReadFromFile(path string) (Inventory, error) {
 	return inv, err
 }
 
+// Write writes the inventory to w. If the inventory was read with Read, only the parts of the original document that
+// changed are updated, keeping its comments, ordering and quoting.
 func (i *Inventory) Write(w io.Writer) error {
+	var node yaml.Node
+	if err := node.Encode(i); err != nil {
+		return err
+	}
+	out := &node
+	if i.node != nil && len(i.node.Content) == 1 {
+		mergeNode(i.node.Content[0], &node)
+		out = i.node
+	}
 	enc := yaml.NewEncoder(w)
 	defer func() { _ = enc.Close() }()
 	enc.SetIndent(2)
-	return enc.Encode(i)
+	return enc.Encode(out)
 }
 
 func (i *Inventory) WriteToFile(filename string) error {
-	f, err := os.Create(filename)
-	if err == nil {
-		err = i.Write(f)
-		_ = f.Close()
+	return writeFile(filename, i.Write)
+}
+
+// Cluster returns the cluster with the given name.
+func (i *Inventory) Cluster(name string) (Cluster, bool) {
+	for _, cluster := range i.Clusters {
+		if cluster.Name == name {
+			return cluster, true
+		}
 	}
-	return err
+	return Cluster{}, false
 }
 
+// SecretScope returns the sealing scope of the secret. If the secret doesn't set a scope, it returns the inventory's default.
+func (i *Inventory) SecretScope(secret Secret) string {
+	if secret.Scope != "" {
+		return secret.Scope
+	}
+	return i.Scope
+}
+
+// Add adds the secret to the inventory. If the inventory already has a secret with the same source, it is updated in place.
 func (i *Inventory) Add(secret Secret) {
-	i.Delete(secret.Source)
+	for idx := range i.Secrets {
+		if i.Secrets[idx].Source == secret.Source {
+			i.Secrets[idx] = secret
+			return
+		}
+	}
 	i.Secrets = append(i.Secrets, secret)
 }
 
@@ -5,6 +5,8 @@ import (
 	"github.com/clambin/seals/internal/inventory"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -36,3 +38,116 @@ secrets:
 	assert.False(t, inv.Delete("bar.yaml"))
 	assert.True(t, inv.Delete("foo.yaml"))
 }
+
+func TestInventory_WriteToFile(t *testing.T) {
+	tmpdir := t.TempDir()
+	filename := filepath.Join(tmpdir, "inventory.yaml")
+	inv := inventory.Inventory{SecretsDir: "../secrets", DestinationDir: "../manifests"}
+	require.NoError(t, inv.WriteToFile(filename))
+
+	// an existing file keeps its permissions
+	require.NoError(t, os.Chmod(filename, 0600))
+	inv.Add(inventory.Secret{Source: "foo.yaml", Destination: "sealed-foo.yaml", Namespace: "default"})
+	require.NoError(t, inv.WriteToFile(filename))
+	fi, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	got, err := inventory.ReadFromFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, inv.Secrets, got.Secrets)
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(tmpdir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestInventory_SecretScope(t *testing.T) {
+	var inv inventory.Inventory
+	assert.Empty(t, inv.SecretScope(inventory.Secret{}))
+	assert.Equal(t, "cluster-wide", inv.SecretScope(inventory.Secret{Scope: "cluster-wide"}))
+	inv.Scope = "namespace-wide"
+	assert.Equal(t, "namespace-wide", inv.SecretScope(inventory.Secret{}))
+	assert.Equal(t, "cluster-wide", inv.SecretScope(inventory.Secret{Scope: "cluster-wide"}))
+}
+
+func TestInventory_Clusters(t *testing.T) {
+	inv, err := inventory.Read(bytes.NewBufferString(`secrets_dir: secrets
+destination_dir: manifests
+clusters:
+  - name: staging
+    context: staging-admin
+  - name: production
+    cert: production.pem
+secrets:
+  - source: foo.yaml
+    namespace: default
+    clusters:
+      - cluster: staging
+        destination: staging/sealed-foo.yaml
+      - cluster: production
+        destination: production/sealed-foo.yaml
+  - source: bar.yaml
+    namespace: default
+    destination: sealed-bar.yaml
+`))
+	require.NoError(t, err)
+
+	cluster, ok := inv.Cluster("staging")
+	require.True(t, ok)
+	assert.Equal(t, "staging-admin", cluster.Context)
+	_, ok = inv.Cluster("dev")
+	assert.False(t, ok)
+
+	assert.Equal(t, []inventory.Target{
+		{Cluster: "staging", Destination: "staging/sealed-foo.yaml"},
+		{Cluster: "production", Destination: "production/sealed-foo.yaml"},
+	}, inv.Secrets[0].Targets())
+	assert.Equal(t, []inventory.Target{{Destination: "sealed-bar.yaml"}}, inv.Secrets[1].Targets())
+}
+
+func TestInventory_Write_Preserves_Formatting(t *testing.T) {
+	inv, err := inventory.Read(bytes.NewBufferString(`# seals inventory
+destination_dir: ../manifests # relative to the ansible directory
+secrets_dir: "../../secrets"
+secrets:
+  # production secrets
+  - source: foo.yaml
+    namespace: default
+    destination: sealed-foo.yaml
+  - source: bar.yaml # to be removed
+    destination: sealed-bar.yaml
+    namespace: default
+  - source: baz.yaml
+    destination: sealed-baz.yaml
+    namespace: 'default'
+    # baz is cluster-wide
+    scope: cluster-wide
+`))
+	require.NoError(t, err)
+
+	assert.True(t, inv.Delete("bar.yaml"))
+	inv.Add(inventory.Secret{Source: "foo.yaml", Destination: "sealed-foo.yaml", Namespace: "other"})
+	inv.Add(inventory.Secret{Source: "qux.yaml", Destination: "sealed-qux.yaml", Namespace: "default"})
+
+	var out bytes.Buffer
+	require.NoError(t, inv.Write(&out))
+	assert.Equal(t, `# seals inventory
+destination_dir: ../manifests # relative to the ansible directory
+secrets_dir: "../../secrets"
+secrets:
+  # production secrets
+  - source: foo.yaml
+    namespace: other
+    destination: sealed-foo.yaml
+  - source: baz.yaml
+    destination: sealed-baz.yaml
+    namespace: 'default'
+    # baz is cluster-wide
+    scope: cluster-wide
+  - source: qux.yaml
+    destination: sealed-qux.yaml
+    namespace: default
+`, out.String())
+}
@@ -0,0 +1,80 @@
+package inventory
+
+import (
+	"errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// Lock records a digest of each source secret at the time it was last sealed. This allows seals to detect changes
+// to a secret independent of the file's modification time, which is lost after a fresh clone or checkout.
+type Lock struct {
+	lock    sync.Mutex
+	Digests map[string]string `yaml:"digests"`
+}
+
+// LockPath returns the path of the lock file for the inventory file at path.
+func LockPath(path string) string {
+	return path + ".lock"
+}
+
+func ReadLock(r io.Reader) (*Lock, error) {
+	var lock Lock
+	if err := yaml.NewDecoder(r).Decode(&lock); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// ReadLockFromFile reads the lock file at path. If the file does not exist, it returns an empty Lock.
+func ReadLockFromFile(path string) (*Lock, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Lock{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return ReadLock(f)
+}
+
+func (l *Lock) Write(w io.Writer) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	enc := yaml.NewEncoder(w)
+	defer func() { _ = enc.Close() }()
+	enc.SetIndent(2)
+	return enc.Encode(l)
+}
+
+func (l *Lock) WriteToFile(filename string) error {
+	return writeFile(filename, l.Write)
+}
+
+// Digest returns the recorded digest for source, or an empty string if none was recorded.
+func (l *Lock) Digest(source string) string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.Digests[source]
+}
+
+// SetDigest records the digest for source.
+func (l *Lock) SetDigest(source, digest string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.Digests == nil {
+		l.Digests = make(map[string]string)
+	}
+	l.Digests[source] = digest
+}
+
+// DeleteDigest removes the digest recorded for source.
+func (l *Lock) DeleteDigest(source string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.Digests, source)
+}
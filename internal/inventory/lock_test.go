@@ -0,0 +1,35 @@
+package inventory_test
+
+import (
+	"bytes"
+	"github.com/clambin/seals/internal/inventory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	lockFile := inventory.LockPath(filepath.Join(t.TempDir(), "inventory.yaml"))
+
+	lock, err := inventory.ReadLockFromFile(lockFile)
+	require.NoError(t, err)
+	assert.Empty(t, lock.Digest("foo.yaml"))
+
+	lock.SetDigest("foo.yaml", "1234")
+	assert.Equal(t, "1234", lock.Digest("foo.yaml"))
+
+	var out bytes.Buffer
+	require.NoError(t, lock.Write(&out))
+	assert.Equal(t, `digests:
+  foo.yaml: "1234"
+`, out.String())
+
+	require.NoError(t, lock.WriteToFile(lockFile))
+	lock, err = inventory.ReadLockFromFile(lockFile)
+	require.NoError(t, err)
+	assert.Equal(t, "1234", lock.Digest("foo.yaml"))
+
+	lock.DeleteDigest("foo.yaml")
+	assert.Empty(t, lock.Digest("foo.yaml"))
+}
@@ -0,0 +1,111 @@
+package inventory
+
+import "gopkg.in/yaml.v3"
+
+// mergeNode updates dst so that it holds the same data as src, while changing as little of dst as possible.
+// This keeps the comments, ordering and quoting of any unchanged parts of dst.
+func mergeNode(dst, src *yaml.Node) {
+	if dst.Kind != src.Kind {
+		replaceNode(dst, src)
+		return
+	}
+	switch dst.Kind {
+	case yaml.MappingNode:
+		mergeMapping(dst, src)
+	case yaml.SequenceNode:
+		mergeSequence(dst, src)
+	case yaml.ScalarNode:
+		if dst.Value != src.Value || dst.ShortTag() != src.ShortTag() {
+			dst.Value = src.Value
+			dst.Tag = src.Tag
+			dst.Style = src.Style
+		}
+	default:
+		replaceNode(dst, src)
+	}
+}
+
+// replaceNode replaces dst with src, but keeps any comments attached to dst.
+func replaceNode(dst, src *yaml.Node) {
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+}
+
+// mergeMapping merges the key/value pairs of src into dst. Keys that are not in src are removed from dst.
+// New keys are added at the end of the mapping.
+func mergeMapping(dst, src *yaml.Node) {
+	content := make([]*yaml.Node, 0, len(src.Content))
+	// update the keys that are already present, in their original order
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		if j := mappingIndex(src, dst.Content[i].Value); j >= 0 {
+			mergeNode(dst.Content[i+1], src.Content[j+1])
+			content = append(content, dst.Content[i], dst.Content[i+1])
+		}
+	}
+	// add any new keys
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		if mappingIndex(dst, src.Content[i].Value) < 0 {
+			content = append(content, src.Content[i], src.Content[i+1])
+		}
+	}
+	dst.Content = content
+}
+
+// mergeSequence merges the items of src into dst. Items are matched by identity (see sameItem), so that
+// items that are added to, or removed from, the sequence don't affect the others.
+func mergeSequence(dst, src *yaml.Node) {
+	if len(dst.Content) == 0 && len(src.Content) > 0 {
+		// an empty flow sequence ("[]") would otherwise stay in flow style
+		dst.Style = src.Style
+	}
+	used := make([]bool, len(dst.Content))
+	content := make([]*yaml.Node, 0, len(src.Content))
+	for _, item := range src.Content {
+		match := -1
+		for j, candidate := range dst.Content {
+			if !used[j] && sameItem(candidate, item) {
+				match = j
+				break
+			}
+		}
+		if match < 0 {
+			content = append(content, item)
+			continue
+		}
+		used[match] = true
+		mergeNode(dst.Content[match], item)
+		content = append(content, dst.Content[match])
+	}
+	dst.Content = content
+}
+
+// sameItem determines if two sequence items refer to the same entry. Mappings are identified by the value of
+// the first key of b (e.g. a secret's source). Scalars are identified by their value.
+func sameItem(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind {
+		return false
+	}
+	switch a.Kind {
+	case yaml.MappingNode:
+		if len(b.Content) < 2 {
+			return false
+		}
+		j := mappingIndex(a, b.Content[0].Value)
+		return j >= 0 && a.Content[j+1].Value == b.Content[1].Value
+	case yaml.ScalarNode:
+		return a.Value == b.Value
+	default:
+		return false
+	}
+}
+
+// mappingIndex returns the index of key in a mapping node's content, or -1 if the key isn't found.
+func mappingIndex(n *yaml.Node, key string) int {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -9,5 +9,6 @@ import (
 func main() {
 	if err := cmd.RootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }